@@ -0,0 +1,454 @@
+package mtdb
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/minetest-go/mtdb/auth"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/sirupsen/logrus"
+)
+
+// player names accepted by the engine: letters, digits, "_" and "-", at most 20 characters
+var playerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,20}$`)
+
+// a table with rows referencing an account
+type accountTable struct {
+	name   string
+	column string
+}
+
+// account tables of the player database, referenced by the player name
+var playerAccountTables = []*accountTable{
+	{name: "player_inventory_items", column: "player"},
+	{name: "player_inventories", column: "player"},
+	{name: "player_metadata", column: "player"},
+	{name: "player", column: "name"},
+}
+
+// returns the opened database with the given name or nil if not configured
+func (ctx *Context) database(name string) *contextDatabase {
+	for _, cdb := range ctx.databases {
+		if cdb.name == name {
+			return cdb
+		}
+	}
+	return nil
+}
+
+// open transactions of an account operation, one per database
+type accountTxs map[string]*sql.Tx
+
+// begins a transaction on each of the given databases that is configured
+func (ctx *Context) beginAccountTxs(names ...string) (accountTxs, error) {
+	txs := accountTxs{}
+	for _, name := range names {
+		cdb := ctx.database(name)
+		if cdb == nil {
+			continue
+		}
+		tx, err := cdb.db.Begin()
+		if err != nil {
+			txs.rollback()
+			return nil, fmt.Errorf("%s database: %v", name, err)
+		}
+		txs[name] = tx
+	}
+	return txs, nil
+}
+
+// rolls back all transactions that are not committed yet
+func (txs accountTxs) rollback() {
+	for _, tx := range txs {
+		tx.Rollback()
+	}
+}
+
+// commits the transaction of the given database if it exists
+func (txs accountTxs) commit(name string) error {
+	tx := txs[name]
+	if tx == nil {
+		return nil
+	}
+	err := tx.Commit()
+	if err != nil {
+		return fmt.Errorf("%s database: %v", name, err)
+	}
+	return nil
+}
+
+// deletes (or counts if dry_run is set) the rows of the table referencing the account
+func cleanupRows(tx *sql.Tx, t *accountTable, value any, dry_run bool) (int64, error) {
+	if dry_run {
+		var count int64
+		err := tx.QueryRow(fmt.Sprintf("select count(*) from %s where %s = $1", t.name, t.column), value).Scan(&count)
+		return count, err
+	}
+	res, err := tx.Exec(fmt.Sprintf("delete from %s where %s = $1", t.name, t.column), value)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// removes (or counts if dry_run is set) the auth entry and privileges of the account if it still matches the search,
+// returns false if the account changed since it was selected
+func cleanupAuthAccount(tx *sql.Tx, id int64, search *auth.AuthSearch, dry_run bool, report *CleanupReport) (bool, error) {
+	cond, args := search.Condition(2)
+	args = append([]any{id}, args...)
+
+	var privs, matched int64
+	err := tx.QueryRow("select count(*) from user_privileges where id = $1", id).Scan(&privs)
+	if err != nil {
+		return false, err
+	}
+	if dry_run {
+		err = tx.QueryRow("select count(*) from auth where id = $1 and "+cond, args...).Scan(&matched)
+	} else {
+		var res sql.Result
+		res, err = tx.Exec("delete from auth where id = $1 and "+cond, args...)
+		if err == nil {
+			matched, err = res.RowsAffected()
+		}
+		if err == nil && matched > 0 {
+			// foreign keys are not enforced on sqlite
+			_, err = tx.Exec("delete from user_privileges where id = $1", id)
+		}
+	}
+	if err != nil || matched == 0 {
+		return false, err
+	}
+
+	report.Rows[DATABASE_AUTH+".auth"] += matched
+	report.Rows[DATABASE_AUTH+".user_privileges"] += privs
+	return true, nil
+}
+
+// CleanupOptions selects the accounts removed by CleanupAccounts
+type CleanupOptions struct {
+	// criteria of the removed accounts, the limit and order are ignored
+	Search *auth.AuthSearch
+	// has to be set to remove all accounts if the search has no criteria
+	All bool
+	// only count the affected rows, nothing is deleted
+	DryRun bool
+	// mods with storage entries keyed by the player name, these entries are removed too
+	ModStorageMods []string
+}
+
+// CleanupReport contains the removed accounts and the number of affected rows
+type CleanupReport struct {
+	DryRun   bool     `json:"dry_run"`
+	Accounts []string `json:"accounts"`
+	// affected rows per "<database>.<table>", for example "player.player_metadata"
+	Rows map[string]int64 `json:"rows"`
+}
+
+// CleanupAccounts removes the matching accounts with their privileges, player data, metadata and inventories
+// from all configured databases. The criteria are checked again on removal, accounts changed in the meantime
+// (for example by a login) are kept. The player database is committed before the auth database,
+// an interrupted cleanup can be repeated with the same criteria
+func (ctx *Context) CleanupAccounts(opts *CleanupOptions) (*CleanupReport, error) {
+	if ctx.Auth == nil {
+		return nil, errors.New("no auth database configured")
+	}
+	if ctx.ReadOnly && !opts.DryRun {
+		return nil, types.ErrReadOnly
+	}
+	search := opts.Search
+	if search == nil {
+		search = &auth.AuthSearch{}
+	}
+	if _, args := search.Condition(1); len(args) == 0 && !opts.All {
+		return nil, errors.New("no cleanup criteria given, set the All option to remove all accounts")
+	}
+
+	accounts := []*auth.AuthEntry{}
+	err := ctx.Auth.Iterate(search, func(e *auth.AuthEntry) error {
+		accounts = append(accounts, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CleanupReport{
+		DryRun:   opts.DryRun,
+		Accounts: []string{},
+		Rows:     map[string]int64{},
+	}
+	if len(accounts) == 0 {
+		return report, nil
+	}
+
+	txs, err := ctx.beginAccountTxs(DATABASE_PLAYER, DATABASE_AUTH)
+	if err != nil {
+		return nil, err
+	}
+	defer txs.rollback()
+
+	for _, e := range accounts {
+		matched, err := cleanupAuthAccount(txs[DATABASE_AUTH], *e.ID, search, opts.DryRun, report)
+		if err != nil {
+			return nil, fmt.Errorf("cleanup of account '%s' failed: %v", e.Name, err)
+		}
+		if !matched {
+			logrus.WithField("name", e.Name).Info("Account changed since the search, skipping")
+			continue
+		}
+		report.Accounts = append(report.Accounts, e.Name)
+
+		if tx := txs[DATABASE_PLAYER]; tx != nil {
+			for _, t := range playerAccountTables {
+				count, err := cleanupRows(tx, t, e.Name, opts.DryRun)
+				if err != nil {
+					return nil, fmt.Errorf("cleanup of player '%s' in table %s failed: %v", e.Name, t.name, err)
+				}
+				report.Rows[DATABASE_PLAYER+"."+t.name] += count
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return report, ctx.cleanupModStorage(opts.ModStorageMods, report)
+	}
+
+	err = txs.commit(DATABASE_PLAYER)
+	if err != nil {
+		return nil, err
+	}
+	// mod storage writes go through the repository to keep the audit history
+	err = ctx.cleanupModStorage(opts.ModStorageMods, report)
+	if err != nil {
+		return nil, err
+	}
+	err = txs.commit(DATABASE_AUTH)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// removes (or counts in dry-run mode) the storage entries of the given mods keyed by the account names
+func (ctx *Context) cleanupModStorage(mods []string, report *CleanupReport) error {
+	if ctx.ModStorage == nil {
+		return nil
+	}
+	for _, modname := range mods {
+		for _, name := range report.Accounts {
+			entry, err := ctx.ModStorage.Get(modname, []byte(name))
+			if err != nil {
+				return fmt.Errorf("mod_storage database: %v", err)
+			}
+			if entry == nil {
+				continue
+			}
+			if !report.DryRun {
+				err = ctx.ModStorage.Delete(modname, []byte(name))
+				if err != nil {
+					return fmt.Errorf("mod_storage database: %v", err)
+				}
+			}
+			report.Rows[DATABASE_MOD_STORAGE+"."+modname]++
+		}
+	}
+	return nil
+}
+
+// RenamePlayer renames the account and the player data with metadata and inventories in all configured databases.
+// The storage entries of the given mods keyed by the old player name are moved to the new name.
+// Fails if the new name is already taken, ignoring the case. Already committed changes are reverted if a later step fails
+func (ctx *Context) RenamePlayer(old_name, new_name string, modstorage_mods ...string) error {
+	if ctx.ReadOnly {
+		return types.ErrReadOnly
+	}
+	if !playerNamePattern.MatchString(new_name) || new_name == old_name {
+		return fmt.Errorf("invalid new player name: '%s'", new_name)
+	}
+
+	txs, err := ctx.beginAccountTxs(DATABASE_PLAYER, DATABASE_AUTH)
+	if err != nil {
+		return err
+	}
+	defer txs.rollback()
+
+	found := false
+	for name, tx := range txs {
+		exists, err := renamePrepare(tx, name, old_name, new_name)
+		if err != nil {
+			return err
+		}
+		found = found || exists
+	}
+	if !found {
+		return fmt.Errorf("player '%s' not found", old_name)
+	}
+	if ctx.ModStorage != nil {
+		for _, modname := range modstorage_mods {
+			entry, err := ctx.ModStorage.Get(modname, []byte(new_name))
+			if err != nil {
+				return fmt.Errorf("mod_storage database: %v", err)
+			}
+			if entry != nil {
+				return fmt.Errorf("mod storage key '%s' of mod '%s' already exists", new_name, modname)
+			}
+		}
+	}
+
+	for name, tx := range txs {
+		err = renameRows(tx, name, old_name, new_name)
+		if err != nil {
+			return fmt.Errorf("%s database: %v", name, err)
+		}
+	}
+
+	// the auth database is committed last, the other changes are reverted if a later step fails
+	err = txs.commit(DATABASE_PLAYER)
+	if err != nil {
+		return err
+	}
+	moved, err := ctx.renameModStorage(modstorage_mods, old_name, new_name)
+	if err == nil {
+		err = txs.commit(DATABASE_AUTH)
+	}
+	if err != nil {
+		ctx.revertRename(moved, txs[DATABASE_PLAYER] != nil, old_name, new_name)
+		return err
+	}
+	return nil
+}
+
+// checks the case-insensitive name collisions, returns true if the old name exists in the database
+func renamePrepare(tx *sql.Tx, database, old_name, new_name string) (bool, error) {
+	table := "player"
+	if database == DATABASE_AUTH {
+		table = "auth"
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("select name from %s where name = $1 or upper(name) = $2", table), old_name, strings.ToUpper(new_name))
+	if err != nil {
+		return false, fmt.Errorf("%s database: %v", database, err)
+	}
+	defer rows.Close()
+
+	exists := false
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return false, fmt.Errorf("%s database: %v", database, err)
+		}
+		if name == old_name {
+			exists = true
+		} else {
+			return false, fmt.Errorf("player name '%s' collides with the existing %s entry '%s'", new_name, table, name)
+		}
+	}
+	return exists, rows.Err()
+}
+
+// renames the account rows of the database
+func renameRows(tx *sql.Tx, database, old_name, new_name string) error {
+	if database == DATABASE_AUTH {
+		_, err := tx.Exec("update auth set name = $1 where name = $2", new_name, old_name)
+		return err
+	}
+
+	// the referencing tables are moved to a copy of the player row to satisfy the foreign keys
+	_, err := tx.Exec(`
+		insert into player(name,pitch,yaw,posx,posy,posz,hp,breath,creation_date,modification_date)
+		select $1,pitch,yaw,posx,posy,posz,hp,breath,creation_date,modification_date from player where name = $2`,
+		new_name, old_name)
+	if err != nil {
+		return err
+	}
+	for _, t := range playerAccountTables {
+		if t.name == "player" {
+			continue
+		}
+		_, err = tx.Exec(fmt.Sprintf("update %s set %s = $1 where %s = $2", t.name, t.column, t.column), new_name, old_name)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("delete from player where name = $1", old_name)
+	return err
+}
+
+// moves the storage entries of the given mods to the new key, returns the mods with moved entries
+func (ctx *Context) renameModStorage(mods []string, old_name, new_name string) ([]string, error) {
+	moved := []string{}
+	if ctx.ModStorage == nil {
+		return moved, nil
+	}
+	for _, modname := range mods {
+		written, err := ctx.moveModStorageKey(modname, old_name, new_name)
+		if written {
+			// reverted even if the removal of the old key failed
+			moved = append(moved, modname)
+		}
+		if err != nil {
+			return moved, fmt.Errorf("mod_storage database: %v", err)
+		}
+	}
+	return moved, nil
+}
+
+// moves the storage entry to the new key, returns true if the new key was written.
+// An existing new key with the same value is taken as already moved (the removal of the old key failed before)
+func (ctx *Context) moveModStorageKey(modname, old_key, new_key string) (bool, error) {
+	entry, err := ctx.ModStorage.Get(modname, []byte(old_key))
+	if err != nil || entry == nil {
+		return false, err
+	}
+	ok, err := ctx.ModStorage.SetIf(modname, []byte(new_key), nil, entry.Value)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		existing, err := ctx.ModStorage.Get(modname, []byte(new_key))
+		if err != nil {
+			return false, err
+		}
+		if existing == nil || !bytes.Equal(existing.Value, entry.Value) {
+			return false, fmt.Errorf("key '%s' of mod '%s' already exists", new_key, modname)
+		}
+	}
+	return true, ctx.ModStorage.Delete(modname, []byte(old_key))
+}
+
+// reverts the already committed mod storage and player changes of a failed rename
+func (ctx *Context) revertRename(moved []string, player_committed bool, old_name, new_name string) {
+	for _, modname := range moved {
+		_, err := ctx.moveModStorageKey(modname, new_name, old_name)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"mod": modname, "player": new_name}).Error("reverting the mod storage rename failed")
+		}
+	}
+	if !player_committed {
+		return
+	}
+	err := ctx.revertPlayerRename(old_name, new_name)
+	if err != nil {
+		logrus.WithError(err).WithField("player", new_name).Error("reverting the player rename failed")
+	}
+}
+
+func (ctx *Context) revertPlayerRename(old_name, new_name string) error {
+	txs, err := ctx.beginAccountTxs(DATABASE_PLAYER)
+	if err != nil {
+		return err
+	}
+	defer txs.rollback()
+
+	err = renameRows(txs[DATABASE_PLAYER], DATABASE_PLAYER, new_name, old_name)
+	if err != nil {
+		return err
+	}
+	return txs.commit(DATABASE_PLAYER)
+}
@@ -0,0 +1,330 @@
+package mtdb_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/auth"
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/minetest-go/mtdb/player"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+// creates an account with privileges, player data, metadata, an inventory and a mod storage entry
+func createAccount(t *testing.T, repos *mtdb.Context, name string, last_login int) {
+	e := &auth.AuthEntry{Name: name, Password: "x", LastLogin: last_login}
+	assert.NoError(t, repos.Auth.Create(e))
+	assert.NoError(t, repos.Privs.Create(&auth.PrivilegeEntry{ID: *e.ID, Privilege: "interact"}))
+	assert.NoError(t, repos.Player.CreateOrUpdate(&player.Player{Name: name}))
+	assert.NoError(t, repos.PlayerMetadata.SetPlayerMetadata(&player.PlayerMetadata{Player: name, Metadata: "k", Value: "v"}))
+	assert.NoError(t, repos.ModStorage.Set(&mod_storage.ModStorageEntry{ModName: "homes", Key: []byte(name), Value: []byte("0,0,0")}))
+}
+
+var accountsConfig = map[string]string{
+	worldconfig.CONFIG_MAP_BACKEND:         worldconfig.BACKEND_DUMMY,
+	worldconfig.CONFIG_AUTH_BACKEND:        worldconfig.BACKEND_SQLITE3,
+	worldconfig.CONFIG_PLAYER_BACKEND:      worldconfig.BACKEND_SQLITE3,
+	worldconfig.CONFIG_MOD_STORAGE_BACKEND: worldconfig.BACKEND_SQLITE3,
+}
+
+func newAccountsContext(t *testing.T) (*mtdb.Context, *sql.DB, string) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	repos, err := mtdb.NewWithConfig(tmpdir, accountsConfig)
+	assert.NoError(t, err)
+
+	// direct access to the inventory tables
+	player_db, err := sql.Open("sqlite3", "file:"+path.Join(tmpdir, "players.sqlite"))
+	assert.NoError(t, err)
+	return repos, player_db, tmpdir
+}
+
+func countInventories(t *testing.T, db *sql.DB, name string) int {
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from player_inventories where player = $1", name).Scan(&count))
+	return count
+}
+
+func TestCleanupAccounts(t *testing.T) {
+	repos, player_db, _ := newAccountsContext(t)
+	defer repos.Close()
+	defer player_db.Close()
+
+	createAccount(t, repos, "inactive", 100)
+	createAccount(t, repos, "active", 300)
+	for _, name := range []string{"inactive", "active"} {
+		_, err := player_db.Exec("insert into player_inventories(player,inv_id,inv_width,inv_name,inv_size) values($1,0,8,'main',32)", name)
+		assert.NoError(t, err)
+		_, err = player_db.Exec("insert into player_inventory_items(player,inv_id,slot_id,item) values($1,0,1,'default:dirt')", name)
+		assert.NoError(t, err)
+	}
+
+	before := 200
+	opts := &mtdb.CleanupOptions{
+		Search:         &auth.AuthSearch{LastLoginBefore: &before},
+		DryRun:         true,
+		ModStorageMods: []string{"homes"},
+	}
+	expected_rows := map[string]int64{
+		"auth.auth":                     1,
+		"auth.user_privileges":          1,
+		"player.player":                 1,
+		"player.player_metadata":        1,
+		"player.player_inventories":     1,
+		"player.player_inventory_items": 1,
+		"mod_storage.homes":             1,
+	}
+
+	// dry run
+	report, err := repos.CleanupAccounts(opts)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"inactive"}, report.Accounts)
+	assert.Equal(t, expected_rows, report.Rows)
+
+	e, err := repos.Auth.GetByUsername("inactive")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	assert.Equal(t, 1, countInventories(t, player_db, "inactive"))
+
+	// cleanup
+	opts.DryRun = false
+	report, err = repos.CleanupAccounts(opts)
+	assert.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, []string{"inactive"}, report.Accounts)
+	assert.Equal(t, expected_rows, report.Rows)
+
+	e, err = repos.Auth.GetByUsername("inactive")
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+	privs, err := repos.Privs.GetByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(privs))
+	p, err := repos.Player.GetPlayer("inactive")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	md, err := repos.PlayerMetadata.GetPlayerMetadata("inactive")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(md))
+	assert.Equal(t, 0, countInventories(t, player_db, "inactive"))
+	entry, err := repos.ModStorage.Get("homes", []byte("inactive"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+
+	// active account untouched
+	e, err = repos.Auth.GetByUsername("active")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	p, err = repos.Player.GetPlayer("active")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, 1, countInventories(t, player_db, "active"))
+	entry, err = repos.ModStorage.Get("homes", []byte("active"))
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	// nothing left to clean up
+	report, err = repos.CleanupAccounts(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(report.Accounts))
+}
+
+func TestCleanupAccountsReadOnly(t *testing.T) {
+	repos, player_db, tmpdir := newAccountsContext(t)
+	player_db.Close()
+	createAccount(t, repos, "someone", 100)
+	repos.Close()
+
+	// reopen read-only
+	ro, err := mtdb.NewReadOnlyWithConfig(tmpdir, accountsConfig)
+	assert.NoError(t, err)
+	defer ro.Close()
+
+	_, err = ro.CleanupAccounts(&mtdb.CleanupOptions{})
+	assert.ErrorIs(t, err, types.ErrReadOnly)
+	assert.ErrorIs(t, ro.RenamePlayer("someone", "someone_else"), types.ErrReadOnly)
+
+	// criteria or the All option required
+	_, err = ro.CleanupAccounts(&mtdb.CleanupOptions{DryRun: true})
+	assert.Error(t, err)
+	_, err = ro.CleanupAccounts(&mtdb.CleanupOptions{DryRun: true, Search: &auth.AuthSearch{}})
+	assert.Error(t, err)
+
+	report, err := ro.CleanupAccounts(&mtdb.CleanupOptions{DryRun: true, All: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"someone"}, report.Accounts)
+	assert.Equal(t, int64(1), report.Rows["player.player"])
+}
+
+func TestRenamePlayer(t *testing.T) {
+	repos, player_db, _ := newAccountsContext(t)
+	defer repos.Close()
+	defer player_db.Close()
+
+	createAccount(t, repos, "old", 100)
+	createAccount(t, repos, "Other", 100)
+	_, err := player_db.Exec("insert into player_inventories(player,inv_id,inv_width,inv_name,inv_size) values('old',0,8,'main',32)")
+	assert.NoError(t, err)
+	_, err = player_db.Exec("insert into player_inventory_items(player,inv_id,slot_id,item) values('old',0,1,'default:dirt')")
+	assert.NoError(t, err)
+
+	// invalid names and collisions
+	assert.Error(t, repos.RenamePlayer("old", ""))
+	assert.Error(t, repos.RenamePlayer("old", "old"))
+	// names not allowed by the engine
+	assert.Error(t, repos.RenamePlayer("old", "foo bar"))
+	assert.Error(t, repos.RenamePlayer("old", "n\u00e4me"))
+	assert.Error(t, repos.RenamePlayer("old", strings.Repeat("a", 21)))
+	assert.Error(t, repos.RenamePlayer("nonexistent", "new"))
+	assert.Error(t, repos.RenamePlayer("old", "other"))
+	assert.NoError(t, repos.ModStorage.Set(&mod_storage.ModStorageEntry{ModName: "homes2", Key: []byte("new"), Value: []byte("x")}))
+	assert.Error(t, repos.RenamePlayer("old", "new", "homes2"))
+
+	// nothing changed
+	e, err := repos.Auth.GetByUsername("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	id := *e.ID
+	p, err := repos.Player.GetPlayer("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	// rename
+	assert.NoError(t, repos.RenamePlayer("old", "new", "homes"))
+
+	e, err = repos.Auth.GetByUsername("old")
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+	e, err = repos.Auth.GetByUsername("new")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	assert.Equal(t, id, *e.ID)
+	privs, err := repos.Privs.GetByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(privs))
+
+	p, err = repos.Player.GetPlayer("old")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	p, err = repos.Player.GetPlayer("new")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	md, err := repos.PlayerMetadata.GetPlayerMetadata("new")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(md))
+	assert.Equal(t, 0, countInventories(t, player_db, "old"))
+	assert.Equal(t, 1, countInventories(t, player_db, "new"))
+
+	entry, err := repos.ModStorage.Get("homes", []byte("old"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+	entry, err = repos.ModStorage.Get("homes", []byte("new"))
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	// case-only rename
+	assert.NoError(t, repos.RenamePlayer("new", "New"))
+	e, err = repos.Auth.GetByUsername("New")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+}
+
+// mod storage repository failing the writes of a single mod or the removal of a single key
+type failingModStorage struct {
+	mod_storage.ModStorageRepository
+	modname   string
+	deleteKey string
+}
+
+func (r *failingModStorage) SetIf(modname string, key, expected, value []byte) (bool, error) {
+	if modname == r.modname {
+		return false, errors.New("write failed")
+	}
+	return r.ModStorageRepository.SetIf(modname, key, expected, value)
+}
+
+func (r *failingModStorage) Delete(modname string, key []byte) error {
+	if string(key) == r.deleteKey {
+		return errors.New("delete failed")
+	}
+	return r.ModStorageRepository.Delete(modname, key)
+}
+
+func TestRenamePlayerRevert(t *testing.T) {
+	repos, player_db, _ := newAccountsContext(t)
+	defer repos.Close()
+	defer player_db.Close()
+
+	createAccount(t, repos, "old", 100)
+	_, err := player_db.Exec("insert into player_inventories(player,inv_id,inv_width,inv_name,inv_size) values('old',0,8,'main',32)")
+	assert.NoError(t, err)
+	assert.NoError(t, repos.ModStorage.Set(&mod_storage.ModStorageEntry{ModName: "broken", Key: []byte("old"), Value: []byte("x")}))
+
+	// the player database and the "homes" entry are already moved when the "broken" mod fails
+	repos.ModStorage = &failingModStorage{ModStorageRepository: repos.ModStorage, modname: "broken"}
+	assert.Error(t, repos.RenamePlayer("old", "new", "homes", "broken"))
+
+	e, err := repos.Auth.GetByUsername("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	e, err = repos.Auth.GetByUsername("new")
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+
+	p, err := repos.Player.GetPlayer("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	p, err = repos.Player.GetPlayer("new")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	md, err := repos.PlayerMetadata.GetPlayerMetadata("old")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(md))
+	assert.Equal(t, 1, countInventories(t, player_db, "old"))
+	assert.Equal(t, 0, countInventories(t, player_db, "new"))
+
+	for _, modname := range []string{"homes", "broken"} {
+		entry, err := repos.ModStorage.Get(modname, []byte("old"))
+		assert.NoError(t, err)
+		assert.NotNil(t, entry, modname)
+		entry, err = repos.ModStorage.Get(modname, []byte("new"))
+		assert.NoError(t, err)
+		assert.Nil(t, entry, modname)
+	}
+}
+
+func TestRenamePlayerRevertModStorageDelete(t *testing.T) {
+	repos, player_db, _ := newAccountsContext(t)
+	defer repos.Close()
+	defer player_db.Close()
+
+	createAccount(t, repos, "old", 100)
+
+	// the "homes" entry is written to the new key but the old key can't be removed
+	repos.ModStorage = &failingModStorage{ModStorageRepository: repos.ModStorage, deleteKey: "old"}
+	assert.Error(t, repos.RenamePlayer("old", "new", "homes"))
+
+	e, err := repos.Auth.GetByUsername("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, e)
+	p, err := repos.Player.GetPlayer("old")
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+
+	// only stored under the old name
+	entry, err := repos.ModStorage.Get("homes", []byte("old"))
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+	entry, err = repos.ModStorage.Get("homes", []byte("new"))
+	assert.NoError(t, err)
+	assert.Nil(t, entry)
+}
@@ -20,7 +20,13 @@ func NewAuthRepository(db *sql.DB, dbtype types.DatabaseType) *AuthRepository {
 }
 
 type AuthRepository struct {
-	db *sql.DB
+	db       *sql.DB
+	readonly bool
+}
+
+// SetReadOnly enables or disables the read-only mode, write operations return types.ErrReadOnly if enabled
+func (repo *AuthRepository) SetReadOnly(readonly bool) {
+	repo.readonly = readonly
 }
 
 func (repo *AuthRepository) GetByUsername(username string) (*AuthEntry, error) {
@@ -37,6 +43,7 @@ type OrderColumnType string
 type OrderDirectionType string
 
 const (
+	ID         OrderColumnType    = "id"
 	LastLogin  OrderColumnType    = "last_login"
 	Name       OrderColumnType    = "name"
 	Ascending  OrderDirectionType = "asc"
@@ -44,6 +51,7 @@ const (
 )
 
 var orderColumns = map[OrderColumnType]bool{
+	ID:        true,
 	LastLogin: true,
 	Name:      true,
 }
@@ -60,12 +68,32 @@ type AuthSearch struct {
 	Limit              *int                `json:"limit"`
 	OrderColumn        *OrderColumnType    `json:"order_column"`
 	OrderDirection     *OrderDirectionType `json:"order_direction"`
+	// keyset cursors, only entries after the given id or name are returned ordered by the cursor column.
+	// Search rejects a cursor combined with another order column, a descending order or the other cursor
+	AfterID   *int64  `json:"after_id"`
+	AfterName *string `json:"after_name"`
+	// only entries with a last login before or after the given unix timestamp (exclusive)
+	LastLoginBefore *int `json:"last_login_before"`
+	LastLoginAfter  *int `json:"last_login_after"`
+	// only entries having all or none of the given privileges
+	HasPrivileges   []string `json:"has_privileges"`
+	LacksPrivileges []string `json:"lacks_privileges"`
 }
 
+// number of entries fetched per query in Iterate
+var IterateBatchSize = 1000
+
 func (repo *AuthRepository) buildWhereClause(fields string, s *AuthSearch) (string, []interface{}) {
-	q := `select ` + fields + ` from auth where true `
+	cond, args := s.Condition(1)
+	return `select ` + fields + ` from auth where ` + cond, args
+}
+
+// Condition returns the filters and cursors of the search as sql condition on the "auth" table,
+// the parameters are numbered starting at $first. The limit and order are not included
+func (s *AuthSearch) Condition(first int) (string, []interface{}) {
+	q := `true`
 	args := make([]interface{}, 0)
-	i := 1
+	i := first
 
 	if s.Username != nil {
 		q += fmt.Sprintf(" and name = $%d", i)
@@ -85,6 +113,47 @@ func (repo *AuthRepository) buildWhereClause(fields string, s *AuthSearch) (stri
 		i++
 	}
 
+	if s.AfterID != nil {
+		q += fmt.Sprintf(" and id > $%d", i)
+		args = append(args, *s.AfterID)
+		i++
+	}
+
+	if s.AfterName != nil {
+		q += fmt.Sprintf(" and name > $%d", i)
+		args = append(args, *s.AfterName)
+		i++
+	}
+
+	if s.LastLoginBefore != nil {
+		q += fmt.Sprintf(" and last_login < $%d", i)
+		args = append(args, *s.LastLoginBefore)
+		i++
+	}
+
+	if s.LastLoginAfter != nil {
+		q += fmt.Sprintf(" and last_login > $%d", i)
+		args = append(args, *s.LastLoginAfter)
+		i++
+	}
+
+	for _, priv := range s.HasPrivileges {
+		q += fmt.Sprintf(" and exists (select 1 from user_privileges up where up.id = auth.id and up.privilege = $%d)", i)
+		args = append(args, priv)
+		i++
+	}
+
+	for _, priv := range s.LacksPrivileges {
+		q += fmt.Sprintf(" and not exists (select 1 from user_privileges up where up.id = auth.id and up.privilege = $%d)", i)
+		args = append(args, priv)
+		i++
+	}
+
+	return q, args
+}
+
+func (repo *AuthRepository) buildOrderClause(s *AuthSearch) string {
+	q := ""
 	if s.OrderColumn != nil && orderColumns[*s.OrderColumn] {
 		order := Ascending
 		if s.OrderDirection != nil && orderDirections[*s.OrderDirection] {
@@ -92,6 +161,10 @@ func (repo *AuthRepository) buildWhereClause(fields string, s *AuthSearch) (stri
 		}
 
 		q += fmt.Sprintf(" order by %s %s", *s.OrderColumn, order)
+	} else if s.AfterID != nil {
+		q += " order by id asc"
+	} else if s.AfterName != nil {
+		q += " order by name asc"
 	}
 
 	// limit result length to 1000 per default
@@ -101,15 +174,43 @@ func (repo *AuthRepository) buildWhereClause(fields string, s *AuthSearch) (stri
 	}
 	q += fmt.Sprintf(" limit %d", limit)
 
-	return q, args
+	return q
+}
+
+// checks that a keyset cursor is only used with the ascending order of its column
+func (s *AuthSearch) checkCursor() error {
+	if s.AfterID == nil && s.AfterName == nil {
+		return nil
+	}
+	if s.AfterID != nil && s.AfterName != nil {
+		return fmt.Errorf("the after_id and after_name cursors can't be combined")
+	}
+	col := ID
+	if s.AfterName != nil {
+		col = Name
+	}
+	if s.OrderColumn != nil && *s.OrderColumn != col {
+		return fmt.Errorf("the cursor requires the '%s' order column, got '%s'", col, *s.OrderColumn)
+	}
+	if s.OrderDirection != nil && *s.OrderDirection != Ascending {
+		return fmt.Errorf("the cursor requires the ascending order, got '%s'", *s.OrderDirection)
+	}
+	return nil
 }
 
 func (repo *AuthRepository) Search(s *AuthSearch) ([]*AuthEntry, error) {
+	err := s.checkCursor()
+	if err != nil {
+		return nil, err
+	}
 	q, args := repo.buildWhereClause("id,name,password,last_login", s)
+	q += repo.buildOrderClause(s)
 	rows, err := repo.db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
 	list := make([]*AuthEntry, 0)
 	for rows.Next() {
 		entry := &AuthEntry{}
@@ -119,7 +220,39 @@ func (repo *AuthRepository) Search(s *AuthSearch) ([]*AuthEntry, error) {
 		}
 		list = append(list, entry)
 	}
-	return list, nil
+	return list, rows.Err()
+}
+
+// Iterate calls fn for every entry matching the search filters in ascending id order, starting after s.AfterID if set.
+// The entries are fetched in batches of IterateBatchSize, the limit, order and name cursor of the search are ignored.
+// Iteration stops at the first error returned by fn
+func (repo *AuthRepository) Iterate(s *AuthSearch, fn func(*AuthEntry) error) error {
+	col := ID
+	dir := Ascending
+	limit := IterateBatchSize
+
+	page := *s
+	page.AfterName = nil
+	page.OrderColumn = &col
+	page.OrderDirection = &dir
+	page.Limit = &limit
+
+	for {
+		list, err := repo.Search(&page)
+		if err != nil {
+			return err
+		}
+		for _, entry := range list {
+			err = fn(entry)
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < limit {
+			return nil
+		}
+		page.AfterID = list[len(list)-1].ID
+	}
 }
 
 func (repo *AuthRepository) Count(s *AuthSearch) (int, error) {
@@ -131,21 +264,33 @@ func (repo *AuthRepository) Count(s *AuthSearch) (int, error) {
 }
 
 func (repo *AuthRepository) Create(entry *AuthEntry) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	row := repo.db.QueryRow("insert into auth(name,password,last_login) values($1,$2,$3) returning id", entry.Name, entry.Password, entry.LastLogin)
 	return row.Scan(&entry.ID)
 }
 
 func (repo *AuthRepository) Update(entry *AuthEntry) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := repo.db.Exec("update auth set name = $1, password = $2, last_login = $3 where id = $4", entry.Name, entry.Password, entry.LastLogin, entry.ID)
 	return err
 }
 
 func (repo *AuthRepository) Delete(id int64) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := repo.db.Exec("delete from auth where id = $1", id)
 	return err
 }
 
 func (repo *AuthRepository) DeleteAll() error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := repo.db.Exec("delete from auth")
 	return err
 }
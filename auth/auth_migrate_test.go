@@ -2,6 +2,7 @@ package auth_test
 
 import (
 	"database/sql"
+	"os"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -25,3 +26,19 @@ func TestMigrateAuthPostgres(t *testing.T) {
 
 	assert.NoError(t, auth.MigrateAuthDB(db, types.DATABASE_POSTGRES))
 }
+
+func TestMigrateAuthStatus(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "auth.sqlite")
+	assert.NoError(t, err)
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, auth.MigrateAuthDB(db, types.DATABASE_SQLITE))
+
+	status, err := auth.Migrations.Status(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	assert.Equal(t, "auth", status.Name)
+	assert.Equal(t, status.Latest, status.Current)
+	assert.Equal(t, 0, len(status.Pending))
+}
@@ -18,4 +18,7 @@ func TestPostgresAuthRepo(t *testing.T) {
 	priv_repo := auth.NewPrivilegeRepository(db, types.DATABASE_POSTGRES)
 
 	testAuthRepository(t, auth_repo, priv_repo)
+	testAuthIterate(t, auth_repo)
+	testAuthFilters(t, auth_repo, priv_repo)
+	testPrivSetOperations(t, auth_repo, priv_repo)
 }
@@ -166,6 +166,9 @@ func TestSqliteAuthRepo(t *testing.T) {
 	priv_repo := auth.NewPrivilegeRepository(db, types.DATABASE_SQLITE)
 
 	testAuthRepository(t, auth_repo, priv_repo)
+	testAuthIterate(t, auth_repo)
+	testAuthFilters(t, auth_repo, priv_repo)
+	testPrivSetOperations(t, auth_repo, priv_repo)
 }
 
 func TestSqliteBackup(t *testing.T) {
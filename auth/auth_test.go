@@ -1,9 +1,11 @@
 package auth_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/minetest-go/mtdb/auth"
+	"github.com/minetest-go/mtdb/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -119,3 +121,236 @@ func testAuthRepository(t *testing.T, auth_repo *auth.AuthRepository, priv_repo
 	// delete all
 	assert.NoError(t, auth_repo.DeleteAll())
 }
+
+func testAuthIterate(t *testing.T, auth_repo *auth.AuthRepository) {
+	assert.NoError(t, auth_repo.DeleteAll())
+
+	ids := []int64{}
+	for _, name := range []string{"user3", "user1", "user5", "user2", "user4"} {
+		e := &auth.AuthEntry{Name: name, Password: "x"}
+		assert.NoError(t, auth_repo.Create(e))
+		ids = append(ids, *e.ID)
+	}
+
+	// keyset page by id
+	limit := 2
+	list, err := auth_repo.Search(&auth.AuthSearch{AfterID: &ids[1], Limit: &limit})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "user5", list[0].Name)
+	assert.Equal(t, "user2", list[1].Name)
+
+	// keyset page by name
+	after := "user2"
+	list, err = auth_repo.Search(&auth.AuthSearch{AfterName: &after, Limit: &limit})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "user3", list[0].Name)
+	assert.Equal(t, "user4", list[1].Name)
+
+	count, err := auth_repo.Count(&auth.AuthSearch{AfterName: &after})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// cursors only with the ascending order of their column
+	col := auth.LastLogin
+	_, err = auth_repo.Search(&auth.AuthSearch{AfterName: &after, OrderColumn: &col})
+	assert.Error(t, err)
+	col = auth.Name
+	dir := auth.Descending
+	_, err = auth_repo.Search(&auth.AuthSearch{AfterName: &after, OrderColumn: &col, OrderDirection: &dir})
+	assert.Error(t, err)
+	_, err = auth_repo.Search(&auth.AuthSearch{AfterName: &after, AfterID: &ids[1]})
+	assert.Error(t, err)
+	dir = auth.Ascending
+	list, err = auth_repo.Search(&auth.AuthSearch{AfterName: &after, OrderColumn: &col, OrderDirection: &dir, Limit: &limit})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+
+	// iterate in batches smaller than the result
+	defer func(size int) { auth.IterateBatchSize = size }(auth.IterateBatchSize)
+	auth.IterateBatchSize = 2
+
+	names := []string{}
+	err = auth_repo.Iterate(&auth.AuthSearch{}, func(e *auth.AuthEntry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user3", "user1", "user5", "user2", "user4"}, names)
+
+	// iterate with filter and cursor
+	names = []string{}
+	like := "user%"
+	err = auth_repo.Iterate(&auth.AuthSearch{Usernamelike: &like, AfterID: &ids[2]}, func(e *auth.AuthEntry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user2", "user4"}, names)
+
+	// abort iteration
+	stop := errors.New("stop")
+	names = []string{}
+	err = auth_repo.Iterate(&auth.AuthSearch{}, func(e *auth.AuthEntry) error {
+		names = append(names, e.Name)
+		if len(names) == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 3, len(names))
+
+	assert.NoError(t, auth_repo.DeleteAll())
+}
+
+func testAuthFilters(t *testing.T, auth_repo *auth.AuthRepository, priv_repo *auth.PrivRepository) {
+	assert.NoError(t, auth_repo.DeleteAll())
+
+	users := map[string]*auth.AuthEntry{}
+	for name, last_login := range map[string]int{"old": 100, "mid": 200, "new": 300} {
+		e := &auth.AuthEntry{Name: name, Password: "x", LastLogin: last_login}
+		assert.NoError(t, auth_repo.Create(e))
+		users[name] = e
+	}
+	assert.NoError(t, priv_repo.Create(&auth.PrivilegeEntry{ID: *users["old"].ID, Privilege: "interact"}))
+	assert.NoError(t, priv_repo.Create(&auth.PrivilegeEntry{ID: *users["mid"].ID, Privilege: "interact"}))
+	assert.NoError(t, priv_repo.Create(&auth.PrivilegeEntry{ID: *users["mid"].ID, Privilege: "shout"}))
+
+	names := func(s *auth.AuthSearch) []string {
+		col := auth.Name
+		s.OrderColumn = &col
+		list, err := auth_repo.Search(s)
+		assert.NoError(t, err)
+		result := []string{}
+		for _, e := range list {
+			result = append(result, e.Name)
+		}
+		return result
+	}
+
+	// last login ranges
+	before := 250
+	after := 100
+	assert.Equal(t, []string{"mid", "old"}, names(&auth.AuthSearch{LastLoginBefore: &before}))
+	assert.Equal(t, []string{"mid", "new"}, names(&auth.AuthSearch{LastLoginAfter: &after}))
+	assert.Equal(t, []string{"mid"}, names(&auth.AuthSearch{LastLoginBefore: &before, LastLoginAfter: &after}))
+
+	// privilege filters
+	assert.Equal(t, []string{"mid", "old"}, names(&auth.AuthSearch{HasPrivileges: []string{"interact"}}))
+	assert.Equal(t, []string{"mid"}, names(&auth.AuthSearch{HasPrivileges: []string{"interact", "shout"}}))
+	assert.Equal(t, []string{"new"}, names(&auth.AuthSearch{LacksPrivileges: []string{"interact"}}))
+	assert.Equal(t, []string{"old"}, names(&auth.AuthSearch{HasPrivileges: []string{"interact"}, LacksPrivileges: []string{"shout"}}))
+
+	// combined with count
+	count, err := auth_repo.Count(&auth.AuthSearch{LastLoginBefore: &before, LacksPrivileges: []string{"shout"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	for _, e := range users {
+		assert.NoError(t, priv_repo.Delete(*e.ID, "interact"))
+		assert.NoError(t, priv_repo.Delete(*e.ID, "shout"))
+	}
+	assert.NoError(t, auth_repo.DeleteAll())
+}
+
+func TestAuthSearchCondition(t *testing.T) {
+	cond, args := (&auth.AuthSearch{}).Condition(1)
+	assert.Equal(t, "true", cond)
+	assert.Equal(t, 0, len(args))
+
+	// parameters numbered from the given start
+	before := 100
+	cond, args = (&auth.AuthSearch{LastLoginBefore: &before, LacksPrivileges: []string{"interact"}}).Condition(2)
+	assert.Equal(t, "true and last_login < $2 and not exists (select 1 from user_privileges up where up.id = auth.id and up.privilege = $3)", cond)
+	assert.Equal(t, []interface{}{100, "interact"}, args)
+}
+
+func testPrivSetOperations(t *testing.T, auth_repo *auth.AuthRepository, priv_repo *auth.PrivRepository) {
+	assert.NoError(t, auth_repo.DeleteAll())
+
+	alice := &auth.AuthEntry{Name: "alice", Password: "x"}
+	assert.NoError(t, auth_repo.Create(alice))
+	bob := &auth.AuthEntry{Name: "bob", Password: "x"}
+	assert.NoError(t, auth_repo.Create(bob))
+
+	privNames := func(id int64) map[string]bool {
+		list, err := priv_repo.GetByID(id)
+		assert.NoError(t, err)
+		privs := map[string]bool{}
+		for _, e := range list {
+			privs[e.Privilege] = true
+		}
+		return privs
+	}
+
+	// set all
+	assert.NoError(t, priv_repo.SetAll(*alice.ID, []string{"interact", "shout", "fly"}))
+	assert.Equal(t, map[string]bool{"interact": true, "shout": true, "fly": true}, privNames(*alice.ID))
+	assert.NoError(t, priv_repo.SetAll(*alice.ID, []string{"interact", "fast"}))
+	assert.Equal(t, map[string]bool{"interact": true, "fast": true}, privNames(*alice.ID))
+
+	// grant, duplicates are skipped
+	assert.NoError(t, priv_repo.GrantMany(*bob.ID, []string{"interact", "fly"}))
+	assert.NoError(t, priv_repo.GrantMany(*bob.ID, []string{"fly", "fast"}))
+	assert.Equal(t, map[string]bool{"interact": true, "fly": true, "fast": true}, privNames(*bob.ID))
+
+	// revoke
+	assert.NoError(t, priv_repo.RevokeMany(*bob.ID, []string{"fly", "nonexistent"}))
+	assert.Equal(t, map[string]bool{"interact": true, "fast": true}, privNames(*bob.ID))
+
+	// has priv
+	ok, err := priv_repo.HasPriv(*bob.ID, "fast")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = priv_repo.HasPriv(*bob.ID, "fly")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// list users
+	users, err := priv_repo.ListUsersWithPriv("interact")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, users)
+	users, err = priv_repo.ListUsersWithPriv("fly")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, users)
+
+	// rename, bob already has the new priv
+	assert.NoError(t, priv_repo.GrantMany(*bob.ID, []string{"speed"}))
+	count, err := priv_repo.RenamePriv("fast", "speed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, map[string]bool{"interact": true, "speed": true}, privNames(*alice.ID))
+	assert.Equal(t, map[string]bool{"interact": true, "speed": true}, privNames(*bob.ID))
+
+	// same name, nothing changed
+	count, err = priv_repo.RenamePriv("speed", "speed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, map[string]bool{"interact": true, "speed": true}, privNames(*alice.ID))
+	assert.Equal(t, map[string]bool{"interact": true, "speed": true}, privNames(*bob.ID))
+
+	// revoke from everyone
+	count, err = priv_repo.RevokeFromAll("speed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	users, err = priv_repo.ListUsersWithPriv("speed")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(users))
+
+	// read-only
+	priv_repo.SetReadOnly(true)
+	assert.ErrorIs(t, priv_repo.SetAll(*bob.ID, nil), types.ErrReadOnly)
+	assert.ErrorIs(t, priv_repo.GrantMany(*bob.ID, []string{"fly"}), types.ErrReadOnly)
+	assert.ErrorIs(t, priv_repo.RevokeMany(*bob.ID, []string{"interact"}), types.ErrReadOnly)
+	_, err = priv_repo.RenamePriv("interact", "play")
+	assert.ErrorIs(t, err, types.ErrReadOnly)
+	_, err = priv_repo.RevokeFromAll("interact")
+	assert.ErrorIs(t, err, types.ErrReadOnly)
+	priv_repo.SetReadOnly(false)
+
+	assert.NoError(t, priv_repo.SetAll(*alice.ID, nil))
+	assert.NoError(t, priv_repo.SetAll(*bob.ID, nil))
+	assert.NoError(t, auth_repo.DeleteAll())
+}
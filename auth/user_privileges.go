@@ -12,14 +12,20 @@ type PrivilegeEntry struct {
 }
 
 type PrivRepository struct {
-	db     *sql.DB
-	dbtype types.DatabaseType
+	db       *sql.DB
+	dbtype   types.DatabaseType
+	readonly bool
 }
 
 func NewPrivilegeRepository(db *sql.DB, dbtype types.DatabaseType) *PrivRepository {
 	return &PrivRepository{db: db, dbtype: dbtype}
 }
 
+// SetReadOnly enables or disables the read-only mode, write operations return types.ErrReadOnly if enabled
+func (repo *PrivRepository) SetReadOnly(readonly bool) {
+	repo.readonly = readonly
+}
+
 func (repo *PrivRepository) GetByID(id int64) ([]*PrivilegeEntry, error) {
 	rows, err := repo.db.Query("select id,privilege from user_privileges where id = $1", id)
 	if err != nil {
@@ -38,11 +44,166 @@ func (repo *PrivRepository) GetByID(id int64) ([]*PrivilegeEntry, error) {
 }
 
 func (repo *PrivRepository) Create(entry *PrivilegeEntry) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := repo.db.Exec("insert into user_privileges(id,privilege) values($1,$2)", entry.ID, entry.Privilege)
 	return err
 }
 
 func (repo *PrivRepository) Delete(id int64, privilege string) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := repo.db.Exec("delete from user_privileges where id = $1 and privilege = $2", id, privilege)
 	return err
 }
+
+// SetAll replaces the privileges of the user atomically
+func (repo *PrivRepository) SetAll(id int64, privs []string) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("delete from user_privileges where id = $1", id)
+	if err != nil {
+		return err
+	}
+	err = grantMany(tx, id, privs)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GrantMany adds the privileges to the user, already granted privileges are skipped
+func (repo *PrivRepository) GrantMany(id int64, privs []string) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = grantMany(tx, id, privs)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func grantMany(tx *sql.Tx, id int64, privs []string) error {
+	for _, priv := range privs {
+		_, err := tx.Exec("insert into user_privileges(id,privilege) values($1,$2) on conflict(id,privilege) do nothing", id, priv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeMany removes the privileges from the user, missing privileges are skipped
+func (repo *PrivRepository) RevokeMany(id int64, privs []string) error {
+	if repo.readonly {
+		return types.ErrReadOnly
+	}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, priv := range privs {
+		_, err = tx.Exec("delete from user_privileges where id = $1 and privilege = $2", id, priv)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// HasPriv returns true if the user has the privilege
+func (repo *PrivRepository) HasPriv(id int64, privilege string) (bool, error) {
+	count := 0
+	err := repo.db.QueryRow("select count(*) from user_privileges where id = $1 and privilege = $2", id, privilege).Scan(&count)
+	return count > 0, err
+}
+
+// ListUsersWithPriv returns the names of all users having the privilege, ordered by name
+func (repo *PrivRepository) ListUsersWithPriv(privilege string) ([]string, error) {
+	rows, err := repo.db.Query(`
+		select a.name
+		from auth a
+		join user_privileges up on up.id = a.id
+		where up.privilege = $1
+		order by a.name`, privilege)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]string, 0)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, name)
+	}
+	return list, rows.Err()
+}
+
+// RenamePriv renames the privilege for all users, for example if a mod changed the name of a privilege.
+// Users already having the new privilege keep it. Returns the number of users with the renamed privilege
+func (repo *PrivRepository) RenamePriv(old_privilege, new_privilege string) (int64, error) {
+	if repo.readonly {
+		return 0, types.ErrReadOnly
+	}
+	if old_privilege == new_privilege {
+		// nothing to rename, the merge would remove the privilege
+		return 0, nil
+	}
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("delete from user_privileges where privilege = $1 and id in (select id from user_privileges where privilege = $2)", old_privilege, new_privilege)
+	if err != nil {
+		return 0, err
+	}
+	merged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	res, err = tx.Exec("update user_privileges set privilege = $1 where privilege = $2", new_privilege, old_privilege)
+	if err != nil {
+		return 0, err
+	}
+	renamed, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return merged + renamed, tx.Commit()
+}
+
+// RevokeFromAll removes the privilege from all users, returns the number of affected users
+func (repo *PrivRepository) RevokeFromAll(privilege string) (int64, error) {
+	if repo.readonly {
+		return 0, types.ErrReadOnly
+	}
+	res, err := repo.db.Exec("delete from user_privileges where privilege = $1", privilege)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,129 @@
+package block
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pos is a mapblock position
+type Pos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z"`
+}
+
+// Less compares two positions in iteration order (Z, Y, X)
+func (p Pos) Less(o Pos) bool {
+	if p.Z != o.Z {
+		return p.Z < o.Z
+	}
+	if p.Y != o.Y {
+		return p.Y < o.Y
+	}
+	return p.X < o.X
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d,%d,%d", p.X, p.Y, p.Z)
+}
+
+// ParsePos parses a "x,y,z" string into a position
+func ParsePos(s string) (*Pos, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid position: '%s', expected x,y,z", s)
+	}
+	values := make([]int, 3)
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid position: '%s': %v", s, err)
+		}
+		values[i] = v
+	}
+	return &Pos{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+// Area is an inclusive range of mapblock positions
+type Area struct {
+	Min Pos `json:"min"`
+	Max Pos `json:"max"`
+}
+
+// NewArea creates an area from two corner positions in any order
+func NewArea(p1, p2 Pos) *Area {
+	return &Area{
+		Min: Pos{X: min(p1.X, p2.X), Y: min(p1.Y, p2.Y), Z: min(p1.Z, p2.Z)},
+		Max: Pos{X: max(p1.X, p2.X), Y: max(p1.Y, p2.Y), Z: max(p1.Z, p2.Z)},
+	}
+}
+
+// ParseArea parses a "x1,y1,z1:x2,y2,z2" string into an area
+func ParseArea(s string) (*Area, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid area: '%s', expected x1,y1,z1:x2,y2,z2", s)
+	}
+	p1, err := ParsePos(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	p2, err := ParsePos(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewArea(*p1, *p2), nil
+}
+
+// Contains returns true if the given mapblock position is inside the area
+func (a *Area) Contains(x, y, z int) bool {
+	return x >= a.Min.X && x <= a.Max.X &&
+		y >= a.Min.Y && y <= a.Max.Y &&
+		z >= a.Min.Z && z <= a.Max.Z
+}
+
+// IterateArea calls the given function for every mapblock in the area (or every mapblock if the area is nil)
+// in iteration order (Z, Y, X), starting after the optional "after" position.
+// The iteration stops at the first error returned from the callback.
+func IterateArea(repo BlockRepository, area *Area, after *Pos, fn func(b *Block) error) error {
+	start := Pos{X: MinBlockPos - 1, Y: MinBlockPos - 1, Z: MinBlockPos - 1}
+	if area != nil {
+		// directly after the last position of the previous z-layer
+		start = Pos{X: MaxBlockPos, Y: MaxBlockPos, Z: area.Min.Z - 1}
+	}
+	if after != nil && start.Less(*after) {
+		start = *after
+	}
+
+	ch, closer, err := repo.Iterator(start.X, start.Y, start.Z)
+	if err != nil {
+		return err
+	}
+	stop := func() {
+		closer.Close()
+		// drain remaining blocks to let the iterator finish up
+		for range ch {
+		}
+	}
+
+	for b := range ch {
+		if area != nil {
+			if b.PosZ > area.Max.Z {
+				stop()
+				break
+			}
+			if !area.Contains(b.PosX, b.PosY, b.PosZ) {
+				continue
+			}
+		}
+
+		err = fn(b)
+		if err != nil {
+			stop()
+			return err
+		}
+	}
+
+	return nil
+}
@@ -4,10 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/minetest-go/mtdb/types"
 )
 
+const (
+	// lowest valid mapblock coordinate
+	MinBlockPos = -2048
+	// highest valid mapblock coordinate
+	MaxBlockPos = 2047
+)
+
 type Block struct {
 	PosX int    `json:"x"`
 	PosY int    `json:"y"`
@@ -53,10 +61,19 @@ type BlockRepository interface {
 	// Count returns the total number of stored blocks in the map database.
 	Count() (int64, error)
 
+	// Watch returns a channel that emits the positions of changed (created,
+	// updated or deleted) map blocks until the Closer is called.
+	// The change-tracking triggers have to be installed with MigrateBlockWatchDB
+	// (or the WatchBlocks context option) first.
+	Watch() (chan *Pos, types.Closer, error)
+
 	// Close gracefully finishes the connection with the database backend.
 	Close() error
 }
 
+// WatchPollInterval is the interval in which the Watch implementations check for changes
+var WatchPollInterval = time.Second
+
 // NewBlockRepository initializes the connection with the appropriate database
 // backend and returns the BlockRepository implementation suited for it.
 func NewBlockRepository(db *sql.DB, dbtype types.DatabaseType) (BlockRepository, error) {
@@ -2,18 +2,104 @@ package block
 
 import (
 	"database/sql"
+	"fmt"
 
+	"github.com/minetest-go/mtdb/schema"
 	"github.com/minetest-go/mtdb/types"
 )
 
+// Migrations contains the numbered schema migrations of the blocks database,
+// the initial schema is compatible with the tables created by the engine
+var Migrations = &schema.Set{
+	Name: "blocks",
+	Migrations: map[types.DatabaseType][]*schema.Migration{
+		types.DATABASE_SQLITE: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`CREATE TABLE IF NOT EXISTS blocks (pos INT PRIMARY KEY, data BLOB)`)},
+			{Version: 2, Description: "iteration order index of the x,y,z layout", Up: createIteratorIndex},
+		},
+		types.DATABASE_POSTGRES: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`CREATE TABLE IF NOT EXISTS
+			blocks (posX INT NOT NULL, posY INT NOT NULL, posZ INT NOT NULL, data BYTEA, PRIMARY KEY (posX,posY,posZ))`)},
+		},
+	},
+}
+
+// index in iteration order (z,y,x) for the x,y,z layout, the primary key of the engine is (x,z,y)
+const iteratorIndexStatement = `CREATE INDEX IF NOT EXISTS blocks_zyx ON blocks (z, y, x)`
+
+// creates the iteration order index if the blocks table is in the x,y,z layout
+func createIteratorIndex(tx *sql.Tx) error {
+	count := 0
+	err := tx.QueryRow("select count(*) from pragma_table_info('blocks') where name = 'z'").Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+	_, err = tx.Exec(iteratorIndexStatement)
+	return err
+}
+
 func MigrateBlockDB(db *sql.DB, dbtype types.DatabaseType) error {
+	return Migrations.Migrate(db, dbtype)
+}
+
+const (
+	// sqlite change-log table used by the Watch implementation
+	watchTableName = "blocks_changes"
+	// maximum number of entries in the sqlite change-log table
+	watchTableSize = 100000
+	// postgres trigger, function and notification channel name
+	watchTriggerName = "blocks_notify_change"
+)
+
+// MigrateBlockWatchDB installs the opt-in change-tracking triggers used by BlockRepository.Watch.
+// On sqlite the changed positions are recorded in a size-limited "blocks_changes" table,
+// on postgres a notification on the "blocks_notify_change" channel is sent for every change
+func MigrateBlockWatchDB(db *sql.DB, dbtype types.DatabaseType) error {
 	var err error
 	switch dbtype {
 	case types.DATABASE_SQLITE:
-		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS blocks (pos INT PRIMARY KEY, data BLOB)`)
+		repo := &sqliteBlockRepository{db: db}
+		err = repo.checkNewRowFormat()
+		if err != nil {
+			return err
+		}
+
+		// plain position expressions for the new and old rows
+		newpos, oldpos := "NEW.pos", "OLD.pos"
+		if !repo.has_pos_column {
+			newpos = "NEW.z*16777216 + NEW.y*4096 + NEW.x"
+			oldpos = "OLD.z*16777216 + OLD.y*4096 + OLD.x"
+		}
+		prune := fmt.Sprintf("DELETE FROM %s WHERE id <= last_insert_rowid() - %d;", watchTableName, watchTableSize)
+
+		_, err = db.Exec(fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %[1]s (id INTEGER PRIMARY KEY AUTOINCREMENT, pos INT NOT NULL);
+			CREATE TRIGGER IF NOT EXISTS %[1]s_insert AFTER INSERT ON blocks BEGIN
+				INSERT INTO %[1]s(pos) VALUES(%[2]s); %[4]s
+			END;
+			CREATE TRIGGER IF NOT EXISTS %[1]s_update AFTER UPDATE ON blocks BEGIN
+				INSERT INTO %[1]s(pos) VALUES(%[2]s); %[4]s
+			END;
+			CREATE TRIGGER IF NOT EXISTS %[1]s_delete AFTER DELETE ON blocks BEGIN
+				INSERT INTO %[1]s(pos) VALUES(%[3]s); %[4]s
+			END;
+		`, watchTableName, newpos, oldpos, prune))
 	case types.DATABASE_POSTGRES:
-		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS
-			blocks (posX INT NOT NULL, posY INT NOT NULL, posZ INT NOT NULL, data BYTEA, PRIMARY KEY (posX,posY,posZ))`)
+		_, err = db.Exec(fmt.Sprintf(`
+			CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					PERFORM pg_notify('%[1]s', OLD.posX || ',' || OLD.posY || ',' || OLD.posZ);
+					RETURN OLD;
+				END IF;
+				PERFORM pg_notify('%[1]s', NEW.posX || ',' || NEW.posY || ',' || NEW.posZ);
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+			DROP TRIGGER IF EXISTS %[1]s ON blocks;
+			CREATE TRIGGER %[1]s AFTER INSERT OR UPDATE OR DELETE ON blocks
+				FOR EACH ROW EXECUTE FUNCTION %[1]s();
+		`, watchTriggerName))
 	}
 	return err
 }
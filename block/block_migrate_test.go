@@ -24,3 +24,18 @@ func TestMigrateBlockPostgres(t *testing.T) {
 
 	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_POSTGRES))
 }
+
+func TestMigrateBlockWatchSQlite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+
+	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_SQLITE))
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_SQLITE))
+}
+
+func TestMigrateBlockWatchPostgres(t *testing.T) {
+	db := getPostgresDB(t)
+
+	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_POSTGRES))
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_POSTGRES))
+}
@@ -1,7 +1,14 @@
 package block
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
 
 	"github.com/minetest-go/mtdb/types"
 	"github.com/sirupsen/logrus"
@@ -124,6 +131,96 @@ func (repo *postgresBlockRepository) Delete(x, y, z int) error {
 	return err
 }
 
+func (repo *postgresBlockRepository) Watch() (chan *Pos, types.Closer, error) {
+	var installed bool
+	err := repo.db.QueryRow("select count(*) > 0 from pg_trigger where tgname = $1", watchTriggerName).Scan(&installed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !installed {
+		return nil, nil, fmt.Errorf("trigger '%s' not found, install it with MigrateBlockWatchDB", watchTriggerName)
+	}
+
+	// notifications are received on a dedicated connection while it executes queries
+	ctx := context.Background()
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	positions := []*Pos{}
+	err = conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(driver.Conn)
+		if !ok {
+			return errors.New("failed to get postgres connection")
+		}
+		pq.SetNotificationHandler(c, func(n *pq.Notification) {
+			p, err := ParsePos(n.Extra)
+			if err != nil {
+				logrus.WithField("payload", n.Extra).Warning("invalid change notification")
+				return
+			}
+			positions = append(positions, p)
+		})
+		return nil
+	})
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "listen "+watchTriggerName)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	l := logrus.WithField("channel", watchTriggerName)
+	ch := make(chan *Pos)
+	done := make(types.WhenDone, 1)
+
+	go func() {
+		defer close(ch)
+		defer func() {
+			// reset the connection before returning it to the pool
+			conn.ExecContext(ctx, "unlisten *")
+			conn.Raw(func(driverConn any) error {
+				pq.SetNotificationHandler(driverConn.(driver.Conn), nil)
+				return nil
+			})
+			conn.Close()
+		}()
+		ticker := time.NewTicker(WatchPollInterval)
+		defer ticker.Stop()
+
+		l.Debug("watching for changes")
+		for {
+			select {
+			case <-done:
+				l.Debug("watch closed by caller")
+				return
+			case <-ticker.C:
+				// receive pending notifications
+				_, err := conn.ExecContext(ctx, "select 1")
+				if err != nil {
+					l.WithField("err", err).Warning("error receiving notifications")
+					return
+				}
+				pending := positions
+				positions = []*Pos{}
+
+				for _, p := range pending {
+					select {
+					case ch <- p:
+					case <-done:
+						l.Debug("watch closed by caller")
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ch, done, nil
+}
+
 func (repo *postgresBlockRepository) Vacuum() error {
 	_, err := repo.db.Exec("vacuum")
 	return err
@@ -100,3 +100,27 @@ func TestPostgresIteratorCloser(t *testing.T) {
 	r, _ := setupPostgress(t)
 	testIteratorClose(t, r)
 }
+
+func TestPostgresWatch(t *testing.T) {
+	r, db := setupPostgress(t)
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_POSTGRES))
+	testBlocksWatch(t, r)
+}
+
+func TestPostgresIntegrity(t *testing.T) {
+	r, _ := setupPostgress(t)
+
+	assert.NoError(t, r.Update(&block.Block{PosX: 3000, PosY: 0, PosZ: -3000, Data: createLegacyMapblock(t, "default:stone")}))
+	assert.NoError(t, r.Update(&block.Block{PosX: 0, PosY: 0, PosZ: 0, Data: createLegacyMapblock(t, "default:stone")}))
+
+	report, err := block.CheckIntegrity(r, &block.IntegrityOptions{Action: block.INTEGRITY_ACTION_DELETE})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), report.Blocks)
+	assert.Equal(t, 1, len(report.Issues))
+	assert.Equal(t, block.INTEGRITY_OUT_OF_RANGE, report.Issues[0].Type)
+	assert.True(t, report.Issues[0].Fixed)
+
+	count, err := r.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
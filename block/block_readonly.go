@@ -0,0 +1,26 @@
+package block
+
+import (
+	"github.com/minetest-go/mtdb/types"
+)
+
+type readOnlyBlockRepository struct {
+	BlockRepository
+}
+
+// NewReadOnlyBlockRepository wraps the given repository, all write operations return types.ErrReadOnly
+func NewReadOnlyBlockRepository(repo BlockRepository) BlockRepository {
+	return &readOnlyBlockRepository{BlockRepository: repo}
+}
+
+func (repo *readOnlyBlockRepository) Update(block *Block) error {
+	return types.ErrReadOnly
+}
+
+func (repo *readOnlyBlockRepository) Delete(x, y, z int) error {
+	return types.ErrReadOnly
+}
+
+func (repo *readOnlyBlockRepository) Vacuum() error {
+	return types.ErrReadOnly
+}
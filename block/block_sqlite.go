@@ -3,6 +3,7 @@ package block
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/minetest-go/mtdb/types"
 	"github.com/sirupsen/logrus"
@@ -126,18 +127,26 @@ func (repo *sqliteBlockRepository) GetByPos(x, y, z int) (*Block, error) {
 }
 
 func (repo *sqliteBlockRepository) Iterator(x, y, z int) (chan *Block, types.Closer, error) {
-	if !repo.has_pos_column {
-		// no dice
-		return nil, nil, fmt.Errorf("Iterator does not support new x,y,z-style block table")
-	}
-
 	pos := CoordToPlain(x, y, z)
-	rows, err := repo.db.Query(`
-		SELECT pos, data
-		FROM blocks
-		WHERE pos > $1
-		ORDER BY pos
-		`, pos)
+	var rows *sql.Rows
+	var err error
+	if repo.has_pos_column {
+		// legacy pos column
+		rows, err = repo.db.Query(`
+			SELECT pos, data
+			FROM blocks
+			WHERE pos > $1
+			ORDER BY pos
+			`, pos)
+	} else {
+		// x,y,z columns, in the same order as the plain positions (blocks_zyx index)
+		rows, err = repo.db.Query(`
+			SELECT x, y, z, data
+			FROM blocks
+			WHERE (z, y, x) > ($1, $2, $3)
+			ORDER BY z, y, x
+			`, z, y, x)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -169,11 +178,16 @@ func (repo *sqliteBlockRepository) Iterator(x, y, z int) (chan *Block, types.Clo
 					}
 					// Fetch and send to channel
 					b := &Block{}
-					if err = rows.Scan(&pos, &b.Data); err != nil {
+					if repo.has_pos_column {
+						err = rows.Scan(&pos, &b.Data)
+						b.PosX, b.PosY, b.PosZ = PlainToCoord(pos)
+					} else {
+						err = rows.Scan(&b.PosX, &b.PosY, &b.PosZ, &b.Data)
+					}
+					if err != nil {
 						l.Errorf("Failed to read next item from iterator: %v", err)
 						return
 					}
-					b.PosX, b.PosY, b.PosZ = PlainToCoord(pos)
 					ch <- b
 				} else {
 					l.Debug("Iterator finished, closing up rows and channel")
@@ -213,6 +227,68 @@ func (repo *sqliteBlockRepository) Delete(x, y, z int) error {
 	return err
 }
 
+func (repo *sqliteBlockRepository) Watch() (chan *Pos, types.Closer, error) {
+	var lastid int64
+	row := repo.db.QueryRow(fmt.Sprintf("select coalesce(max(id), 0) from %s", watchTableName))
+	err := row.Scan(&lastid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("change-log table not found, install it with MigrateBlockWatchDB: %v", err)
+	}
+
+	l := logrus.WithField("last_id", lastid)
+	ch := make(chan *Pos)
+	done := make(types.WhenDone, 1)
+	q := fmt.Sprintf("select id, pos from %s where id > $1 order by id", watchTableName)
+
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(WatchPollInterval)
+		defer ticker.Stop()
+
+		l.Debug("Watching for changes")
+		for {
+			select {
+			case <-done:
+				l.Debug("Watch closed by caller")
+				return
+			case <-ticker.C:
+				rows, err := repo.db.Query(q, lastid)
+				if err != nil {
+					l.Errorf("Failed to query the change-log: %v", err)
+					return
+				}
+				positions := []*Pos{}
+				for rows.Next() {
+					var pos int64
+					err = rows.Scan(&lastid, &pos)
+					if err != nil {
+						break
+					}
+					p := &Pos{}
+					p.X, p.Y, p.Z = PlainToCoord(pos)
+					positions = append(positions, p)
+				}
+				rows.Close()
+				if err != nil {
+					l.Errorf("Failed to read the change-log: %v", err)
+					return
+				}
+
+				for _, p := range positions {
+					select {
+					case ch <- p:
+					case <-done:
+						l.Debug("Watch closed by caller")
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return ch, done, nil
+}
+
 func (repo *sqliteBlockRepository) Vacuum() error {
 	_, err := repo.db.Exec("vacuum")
 	return err
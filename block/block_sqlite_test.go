@@ -42,6 +42,33 @@ func TestSqliteIterator(t *testing.T) {
 	testBlocksRepositoryIterator(t, r)
 }
 
+func TestSqliteIteratorXYZ(t *testing.T) {
+	_, db := setupSqlite(t)
+	_, err := block.ConvertSqliteLayout(db, block.SQLITE_LAYOUT_XYZ, nil)
+	assert.NoError(t, err)
+
+	r, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	defer r.Close()
+	testBlocksRepositoryIterator(t, r)
+}
+
+// asserts that the x,y,z iterator query runs in index order without sorting
+func assertIteratorIndex(t *testing.T, db *sql.DB) {
+	rows, err := db.Query("EXPLAIN QUERY PLAN SELECT x, y, z, data FROM blocks WHERE (z, y, x) > (0, 0, 0) ORDER BY z, y, x")
+	assert.NoError(t, err)
+	defer rows.Close()
+	plan := ""
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		assert.NoError(t, rows.Scan(&id, &parent, &notused, &detail))
+		plan += detail + "\n"
+	}
+	assert.Contains(t, plan, "blocks_zyx")
+	assert.NotContains(t, plan, "TEMP B-TREE")
+}
+
 func TestSqliteIteratorErrorHandling(t *testing.T) {
 	r, db := setupSqlite(t)
 	defer db.Close()
@@ -56,6 +83,35 @@ func TestSqliteIteratorCloser(t *testing.T) {
 	testIteratorClose(t, r)
 }
 
+func TestSqliteWatch(t *testing.T) {
+	r, db := setupSqlite(t)
+	defer r.Close()
+
+	// not installed
+	_, _, err := r.Watch()
+	assert.Error(t, err)
+
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_SQLITE))
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_SQLITE))
+	testBlocksWatch(t, r)
+}
+
+func TestSqliteWatchMultiColumn(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "map.sqlite")
+	assert.NoError(t, err)
+	copyFileContents("testdata/map_multi_pos_column.sqlite", dbfile.Name())
+
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s", dbfile.Name()))
+	assert.NoError(t, err)
+	assert.NoError(t, wal.EnableWAL(db))
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_SQLITE))
+
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	defer repo.Close()
+	testBlocksWatch(t, repo)
+}
+
 func TestCoordToPlain(t *testing.T) {
 	nodes := []struct {
 		x, y, z int
@@ -97,6 +153,9 @@ func TestBlockRepoLegacy(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, repo)
 
+	// no iteration index needed for the pos layout
+	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_SQLITE))
+
 	b, err := repo.GetByPos(0, 0, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, b)
@@ -117,6 +176,10 @@ func TestBlockRepoMultiColumn(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, repo)
 
+	// iteration index created by the migration
+	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_SQLITE))
+	assertIteratorIndex(t, db)
+
 	b, err := repo.GetByPos(0, 0, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, b)
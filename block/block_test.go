@@ -212,3 +212,34 @@ func testIteratorClose(t *testing.T, r block.BlockRepository) {
 
 	t.Logf("Retrieved %d blocks from a total of %d", count, totalCount)
 }
+
+func testBlocksWatch(t *testing.T, r block.BlockRepository) {
+	oldInterval := block.WatchPollInterval
+	block.WatchPollInterval = 10 * time.Millisecond
+	defer func() { block.WatchPollInterval = oldInterval }()
+
+	ch, closer, err := r.Watch()
+	assert.NoError(t, err)
+	assert.NotNil(t, ch)
+	assert.NotNil(t, closer)
+
+	assert.NoError(t, r.Update(&block.Block{PosX: 1, PosY: -2, PosZ: 3, Data: []byte("x")}))
+	assert.NoError(t, r.Delete(-4, 5, -6))
+
+	received := map[block.Pos]bool{}
+	timeout := time.After(3 * time.Second)
+	for !received[block.Pos{X: 1, Y: -2, Z: 3}] {
+		select {
+		case p := <-ch:
+			received[*p] = true
+		case <-timeout:
+			t.Fatalf("watch timed out, received: %v", received)
+		}
+	}
+
+	assert.NoError(t, closer.Close())
+	for range ch {
+		// drain until closed
+	}
+	assert.NoError(t, r.Delete(1, -2, 3))
+}
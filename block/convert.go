@@ -0,0 +1,253 @@
+package block
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/minetest-go/mtdb/types"
+	"github.com/sirupsen/logrus"
+)
+
+// SqliteLayout is the table layout of a sqlite map database
+type SqliteLayout string
+
+const (
+	// legacy layout: blocks(pos,data), see CoordToPlain()
+	SQLITE_LAYOUT_POS SqliteLayout = "pos"
+	// new layout: blocks(x,y,z,data)
+	SQLITE_LAYOUT_XYZ SqliteLayout = "xyz"
+)
+
+// temporary table used for the copy-and-swap conversion
+const convertTableName = "blocks_convert"
+
+var createTableStatements = map[SqliteLayout]string{
+	SQLITE_LAYOUT_POS: `CREATE TABLE %s (pos INT PRIMARY KEY, data BLOB)`,
+	SQLITE_LAYOUT_XYZ: `CREATE TABLE %s (x INTEGER, y INTEGER, z INTEGER, data BLOB NOT NULL, PRIMARY KEY (x, z, y))`,
+}
+
+// ConvertBatchSize is the number of mapblocks copied at once during a layout conversion
+var ConvertBatchSize = 1000
+
+// ConvertResult contains the counters of a layout conversion
+type ConvertResult struct {
+	From SqliteLayout `json:"from"`
+	To   SqliteLayout `json:"to"`
+	// number of converted mapblocks
+	Blocks int64 `json:"blocks"`
+}
+
+// GetSqliteLayout returns the table layout of the sqlite map database
+func GetSqliteLayout(db *sql.DB) (SqliteLayout, error) {
+	repo := &sqliteBlockRepository{db: db}
+	err := repo.checkNewRowFormat()
+	if err != nil {
+		return "", err
+	}
+	if repo.has_pos_column {
+		return SQLITE_LAYOUT_POS, nil
+	}
+	return SQLITE_LAYOUT_XYZ, nil
+}
+
+// a single copied row
+type convertRow struct {
+	rowid int64
+	pos   Pos
+	data  []byte
+}
+
+// ConvertSqliteLayout converts the sqlite map database into the given layout.
+// The mapblocks are copied into a new table which replaces the old one after the counts, positions and data are verified,
+// everything happens in a single transaction. Existing block repositories on the database have to be re-created afterwards,
+// the optional progress callback is called after each copied batch
+func ConvertSqliteLayout(db *sql.DB, layout SqliteLayout, progress func(converted, total int64)) (*ConvertResult, error) {
+	create, found := createTableStatements[layout]
+	if !found {
+		return nil, fmt.Errorf("unknown layout: '%s'", layout)
+	}
+	current, err := GetSqliteLayout(db)
+	if err != nil {
+		return nil, err
+	}
+	if current == layout {
+		return nil, fmt.Errorf("map is already in the '%s' layout", layout)
+	}
+	result := &ConvertResult{From: current, To: layout}
+
+	watched := 0
+	err = db.QueryRow("select count(*) from sqlite_master where type = 'trigger' and name = $1", watchTableName+"_insert").Scan(&watched)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+	err = tx.QueryRow("select count(*) from blocks").Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", convertTableName))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(fmt.Sprintf(create, convertTableName))
+	if err != nil {
+		return nil, err
+	}
+
+	insert := fmt.Sprintf("insert into %s(pos,data) values($1,$2)", convertTableName)
+	if layout == SQLITE_LAYOUT_XYZ {
+		insert = fmt.Sprintf("insert into %s(x,y,z,data) values($1,$2,$3,$4)", convertTableName)
+	}
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var lastid int64
+	for {
+		batch, err := readConvertBatch(tx, current, lastid)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, row := range batch {
+			err = writeConvertRow(stmt, layout, row)
+			if err != nil {
+				return nil, fmt.Errorf("write error at %s: %v", row.pos, err)
+			}
+		}
+		lastid = batch[len(batch)-1].rowid
+		result.Blocks += int64(len(batch))
+		if progress != nil {
+			progress(result.Blocks, total)
+		}
+	}
+
+	err = verifyConversion(tx, layout)
+	if err != nil {
+		return nil, fmt.Errorf("verification failed: %v", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("DROP TABLE blocks; ALTER TABLE %s RENAME TO blocks;", convertTableName))
+	if err != nil {
+		return nil, err
+	}
+	if layout == SQLITE_LAYOUT_XYZ {
+		_, err = tx.Exec(iteratorIndexStatement)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	if watched > 0 {
+		// the change-tracking triggers are dropped with the old table
+		logrus.Info("Re-installing the change-tracking triggers")
+		err = MigrateBlockWatchDB(db, types.DATABASE_SQLITE)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func readConvertBatch(tx *sql.Tx, layout SqliteLayout, lastid int64) ([]*convertRow, error) {
+	q := "select rowid, pos, data from blocks where rowid > $1 order by rowid limit $2"
+	if layout == SQLITE_LAYOUT_XYZ {
+		q = "select rowid, x, y, z, data from blocks where rowid > $1 order by rowid limit $2"
+	}
+	rows, err := tx.Query(q, lastid, ConvertBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batch := []*convertRow{}
+	for rows.Next() {
+		row := &convertRow{}
+		if layout == SQLITE_LAYOUT_XYZ {
+			err = rows.Scan(&row.rowid, &row.pos.X, &row.pos.Y, &row.pos.Z, &row.data)
+		} else {
+			var pos int64
+			err = rows.Scan(&row.rowid, &pos, &row.data)
+			row.pos.X, row.pos.Y, row.pos.Z = PlainToCoord(pos)
+			if err == nil && CoordToPlain(row.pos.X, row.pos.Y, row.pos.Z) != pos {
+				err = fmt.Errorf("invalid position %d", pos)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+func writeConvertRow(stmt *sql.Stmt, layout SqliteLayout, row *convertRow) error {
+	p := row.pos
+	if p.X < MinBlockPos || p.X > MaxBlockPos || p.Y < MinBlockPos || p.Y > MaxBlockPos || p.Z < MinBlockPos || p.Z > MaxBlockPos {
+		return fmt.Errorf("position out of range")
+	}
+	var err error
+	if layout == SQLITE_LAYOUT_XYZ {
+		_, err = stmt.Exec(p.X, p.Y, p.Z, row.data)
+	} else {
+		_, err = stmt.Exec(CoordToPlain(p.X, p.Y, p.Z), row.data)
+	}
+	return err
+}
+
+// compares the block counts and data sizes of the old and new table
+// and checks that every block is found with the same data at the mapped position in the new layout
+func verifyConversion(tx *sql.Tx, layout SqliteLayout) error {
+	q := "select count(*), coalesce(sum(length(data)), 0) from %s"
+	var count, size, newcount, newsize int64
+	err := tx.QueryRow(fmt.Sprintf(q, "blocks")).Scan(&count, &size)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(fmt.Sprintf(q, convertTableName)).Scan(&newcount, &newsize)
+	if err != nil {
+		return err
+	}
+	if count != newcount {
+		return fmt.Errorf("block count mismatch: %d != %d", count, newcount)
+	}
+	if size != newsize {
+		return fmt.Errorf("data size mismatch: %d != %d", size, newsize)
+	}
+
+	// same mapping as CoordToPlain()
+	pos, xyz := "b", "c"
+	if layout == SQLITE_LAYOUT_POS {
+		pos, xyz = "c", "b"
+	}
+	var matched int64
+	err = tx.QueryRow(fmt.Sprintf(`
+		select count(*)
+		from blocks b
+		join %[1]s c on %[2]s.pos = %[3]s.z * 16777216 + %[3]s.y * 4096 + %[3]s.x and b.data = c.data
+		`, convertTableName, pos, xyz)).Scan(&matched)
+	if err != nil {
+		return err
+	}
+	if matched != count {
+		return fmt.Errorf("position mismatch: %d of %d blocks found at the converted position", matched, count)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package block_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertSqliteLayout(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "map.sqlite")
+	assert.NoError(t, err)
+	assert.NoError(t, copyFileContents("testdata/map_legacy_column.sqlite", dbfile.Name()))
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.NoError(t, block.MigrateBlockWatchDB(db, types.DATABASE_SQLITE))
+
+	// collect all blocks
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	blocks := []*block.Block{}
+	ch, _, err := repo.Iterator(block.MinBlockPos-1, block.MinBlockPos-1, block.MinBlockPos-1)
+	assert.NoError(t, err)
+	for b := range ch {
+		blocks = append(blocks, b)
+	}
+	assert.True(t, len(blocks) > 0)
+
+	layout, err := block.GetSqliteLayout(db)
+	assert.NoError(t, err)
+	assert.Equal(t, block.SQLITE_LAYOUT_POS, layout)
+
+	_, err = block.ConvertSqliteLayout(db, block.SQLITE_LAYOUT_POS, nil)
+	assert.Error(t, err)
+	_, err = block.ConvertSqliteLayout(db, "xy", nil)
+	assert.Error(t, err)
+
+	// convert to x,y,z
+	block.ConvertBatchSize = 7
+	defer func() { block.ConvertBatchSize = 1000 }()
+	progress_calls := 0
+	result, err := block.ConvertSqliteLayout(db, block.SQLITE_LAYOUT_XYZ, func(converted, total int64) {
+		progress_calls++
+		assert.Equal(t, int64(len(blocks)), total)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &block.ConvertResult{From: block.SQLITE_LAYOUT_POS, To: block.SQLITE_LAYOUT_XYZ, Blocks: int64(len(blocks))}, result)
+	assert.Equal(t, (len(blocks)+6)/7, progress_calls)
+
+	layout, err = block.GetSqliteLayout(db)
+	assert.NoError(t, err)
+	assert.Equal(t, block.SQLITE_LAYOUT_XYZ, layout)
+	assertIteratorIndex(t, db)
+
+	repo, err = block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	for _, b := range blocks {
+		b2, err := repo.GetByPos(b.PosX, b.PosY, b.PosZ)
+		assert.NoError(t, err)
+		assert.NotNil(t, b2)
+		assert.Equal(t, b.Data, b2.Data)
+	}
+
+	// same order and positions in the x,y,z iterator
+	ch, _, err = repo.Iterator(block.MinBlockPos-1, block.MinBlockPos-1, block.MinBlockPos-1)
+	assert.NoError(t, err)
+	converted := []*block.Block{}
+	for b := range ch {
+		converted = append(converted, b)
+	}
+	assert.Equal(t, blocks, converted)
+
+	// change-tracking triggers re-installed
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from sqlite_master where type = 'trigger'").Scan(&count))
+	assert.Equal(t, 3, count)
+
+	// and back
+	result, err = block.ConvertSqliteLayout(db, block.SQLITE_LAYOUT_POS, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(blocks)), result.Blocks)
+
+	repo, err = block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	ch, _, err = repo.Iterator(block.MinBlockPos-1, block.MinBlockPos-1, block.MinBlockPos-1)
+	assert.NoError(t, err)
+	converted = []*block.Block{}
+	for b := range ch {
+		converted = append(converted, b)
+	}
+	assert.Equal(t, blocks, converted)
+}
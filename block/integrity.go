@@ -0,0 +1,257 @@
+package block
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/sirupsen/logrus"
+)
+
+type IntegrityIssueType string
+
+const (
+	// the mapblock data can't be decompressed or decoded
+	INTEGRITY_DECODE_ERROR IntegrityIssueType = "decode_error"
+	// the mapblock position is outside of the MinBlockPos/MaxBlockPos limits
+	INTEGRITY_OUT_OF_RANGE IntegrityIssueType = "out_of_range"
+	// legacy sqlite only: the pos value doesn't map to a valid position
+	INTEGRITY_INVALID_POS IntegrityIssueType = "invalid_pos"
+	// legacy sqlite only: the pos value maps to the same position as another entry
+	INTEGRITY_DUPLICATE_POS IntegrityIssueType = "duplicate_pos"
+)
+
+// IntegrityAction is the action to take on bad mapblocks
+type IntegrityAction string
+
+const (
+	// only report the bad mapblocks
+	INTEGRITY_ACTION_NONE IntegrityAction = ""
+	// delete the bad mapblocks
+	INTEGRITY_ACTION_DELETE IntegrityAction = "delete"
+	// copy the bad mapblocks to the quarantine repository and delete them
+	INTEGRITY_ACTION_QUARANTINE IntegrityAction = "quarantine"
+)
+
+// IntegrityOptions configures an integrity check
+type IntegrityOptions struct {
+	Action IntegrityAction
+	// destination for the quarantined mapblocks, required for INTEGRITY_ACTION_QUARANTINE
+	Quarantine BlockRepository
+	// optional progress callback, called every IntegrityProgressInterval mapblocks
+	Progress func(r *IntegrityReport)
+}
+
+// IntegrityIssue is a single bad mapblock
+type IntegrityIssue struct {
+	Type IntegrityIssueType `json:"type"`
+	Pos  Pos                `json:"pos"`
+	// raw pos value, legacy sqlite only
+	RawPos  *int64 `json:"raw_pos,omitempty"`
+	Message string `json:"message"`
+	// the action was applied to the mapblock
+	Fixed bool `json:"fixed"`
+}
+
+// IntegrityReport is the result of an integrity check
+type IntegrityReport struct {
+	// number of checked mapblocks
+	Blocks int64             `json:"blocks"`
+	Issues []*IntegrityIssue `json:"issues"`
+}
+
+// Add appends the mapblock count and issues of another report
+func (r *IntegrityReport) Add(o *IntegrityReport) {
+	r.Blocks += o.Blocks
+	r.Issues = append(r.Issues, o.Issues...)
+}
+
+// IntegrityProgressInterval is the number of checked mapblocks between progress reports
+var IntegrityProgressInterval int64 = 10000
+
+func (opts *IntegrityOptions) validate() error {
+	switch opts.Action {
+	case INTEGRITY_ACTION_NONE, INTEGRITY_ACTION_DELETE:
+		return nil
+	case INTEGRITY_ACTION_QUARANTINE:
+		if opts.Quarantine == nil {
+			return fmt.Errorf("no quarantine repository configured")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action: '%s'", opts.Action)
+	}
+}
+
+// a bad mapblock and its issue
+type badBlock struct {
+	issue *IntegrityIssue
+	block *Block
+}
+
+// CheckIntegrity decodes every mapblock in the repository and checks its position,
+// the configured action is applied to the bad mapblocks after the iteration.
+// On legacy sqlite databases CheckSqlitePositions should run first, otherwise entries
+// with invalid pos values are reported (and fixed) at their wrapped position
+func CheckIntegrity(repo BlockRepository, opts *IntegrityOptions) (*IntegrityReport, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IntegrityReport{Issues: []*IntegrityIssue{}}
+	bad := []*badBlock{}
+
+	// start below the limits to include out-of-range positions
+	ch, _, err := repo.Iterator(math.MinInt32, math.MinInt32, math.MinInt32)
+	if err != nil {
+		return nil, err
+	}
+	for b := range ch {
+		report.Blocks++
+		if opts.Progress != nil && report.Blocks%IntegrityProgressInterval == 0 {
+			opts.Progress(report)
+		}
+
+		pos := Pos{X: b.PosX, Y: b.PosY, Z: b.PosZ}
+		issue := checkBlock(pos, b.Data)
+		if issue != nil {
+			bad = append(bad, &badBlock{issue: issue, block: b})
+		}
+	}
+
+	for _, bb := range bad {
+		report.Issues = append(report.Issues, bb.issue)
+		if opts.Action == INTEGRITY_ACTION_NONE {
+			continue
+		}
+		if opts.Action == INTEGRITY_ACTION_QUARANTINE {
+			err = opts.Quarantine.Update(bb.block)
+			if err != nil {
+				return report, fmt.Errorf("quarantine error at %s: %v", bb.issue.Pos, err)
+			}
+		}
+		err = repo.Delete(bb.block.PosX, bb.block.PosY, bb.block.PosZ)
+		if err != nil {
+			return report, fmt.Errorf("delete error at %s: %v", bb.issue.Pos, err)
+		}
+		bb.issue.Fixed = true
+	}
+
+	return report, nil
+}
+
+// returns the issue of a single mapblock or nil if it is valid
+func checkBlock(pos Pos, data []byte) *IntegrityIssue {
+	if pos.X < MinBlockPos || pos.X > MaxBlockPos ||
+		pos.Y < MinBlockPos || pos.Y > MaxBlockPos ||
+		pos.Z < MinBlockPos || pos.Z > MaxBlockPos {
+		return &IntegrityIssue{
+			Type:    INTEGRITY_OUT_OF_RANGE,
+			Pos:     pos,
+			Message: fmt.Sprintf("position outside of the limits %d to %d", MinBlockPos, MaxBlockPos),
+		}
+	}
+
+	m, err := ParseMapblock(data)
+	if err == nil {
+		for _, id := range m.Param0 {
+			if _, found := m.Mapping[id]; !found {
+				err = fmt.Errorf("node-id %d not found in the mapping", id)
+				break
+			}
+		}
+	}
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"pos": []int{pos.X, pos.Y, pos.Z},
+			"err": err,
+		}).Warn("invalid mapblock")
+		return &IntegrityIssue{Type: INTEGRITY_DECODE_ERROR, Pos: pos, Message: err.Error()}
+	}
+	return nil
+}
+
+// CheckSqlitePositions checks the raw pos values of a legacy sqlite map database for entries that don't map
+// to a valid position, those are either unreachable or shadow another entry with the same wrapped position.
+// The configured action is applied to the bad entries, quarantined entries are stored at their wrapped position
+func CheckSqlitePositions(db *sql.DB, opts *IntegrityOptions) (*IntegrityReport, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := GetSqliteLayout(db)
+	if err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{Issues: []*IntegrityIssue{}}
+	if layout != SQLITE_LAYOUT_POS {
+		// the x,y,z layout has no raw positions
+		return report, nil
+	}
+
+	rows, err := db.Query("select pos from blocks")
+	if err != nil {
+		return nil, err
+	}
+	invalid := []int64{}
+	for rows.Next() {
+		var pos int64
+		err = rows.Scan(&pos)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Blocks++
+		x, y, z := PlainToCoord(pos)
+		if CoordToPlain(x, y, z) != pos {
+			invalid = append(invalid, pos)
+		}
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	for _, rawpos := range invalid {
+		issue := &IntegrityIssue{
+			Type:    INTEGRITY_INVALID_POS,
+			RawPos:  &rawpos,
+			Message: "pos value out of range",
+		}
+		issue.Pos.X, issue.Pos.Y, issue.Pos.Z = PlainToCoord(rawpos)
+
+		count := 0
+		err = db.QueryRow("select count(*) from blocks where pos = $1", CoordToPlain(issue.Pos.X, issue.Pos.Y, issue.Pos.Z)).Scan(&count)
+		if err != nil {
+			return report, err
+		}
+		if count > 0 {
+			issue.Type = INTEGRITY_DUPLICATE_POS
+			issue.Message = fmt.Sprintf("pos value maps to the same position as %d", CoordToPlain(issue.Pos.X, issue.Pos.Y, issue.Pos.Z))
+		}
+		report.Issues = append(report.Issues, issue)
+
+		if opts.Action == INTEGRITY_ACTION_NONE {
+			continue
+		}
+		if opts.Action == INTEGRITY_ACTION_QUARANTINE {
+			b := &Block{PosX: issue.Pos.X, PosY: issue.Pos.Y, PosZ: issue.Pos.Z}
+			err = db.QueryRow("select data from blocks where pos = $1", rawpos).Scan(&b.Data)
+			if err != nil {
+				return report, err
+			}
+			err = opts.Quarantine.Update(b)
+			if err != nil {
+				return report, fmt.Errorf("quarantine error at %d: %v", rawpos, err)
+			}
+		}
+		_, err = db.Exec("delete from blocks where pos = $1", rawpos)
+		if err != nil {
+			return report, fmt.Errorf("delete error at %d: %v", rawpos, err)
+		}
+		issue.Fixed = true
+	}
+
+	return report, nil
+}
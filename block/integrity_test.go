@@ -0,0 +1,131 @@
+package block_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "map.sqlite")
+	assert.NoError(t, err)
+	assert.NoError(t, copyFileContents("testdata/map_legacy_column.sqlite", dbfile.Name()))
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+
+	count, err := repo.Count()
+	assert.NoError(t, err)
+
+	// valid map
+	report, err := block.CheckIntegrity(repo, &block.IntegrityOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, count, report.Blocks)
+	assert.Equal(t, 0, len(report.Issues))
+
+	// corrupt block
+	assert.NoError(t, repo.Update(&block.Block{PosX: 10, PosY: 11, PosZ: 12, Data: []byte{29, 0x01, 0x02}}))
+
+	// invalid and duplicate raw pos values
+	invalid := int64(1<<36) + block.CoordToPlain(100, 100, 100)
+	duplicate := int64(1 << 36)
+	_, err = db.Exec("insert into blocks(pos, data) values($1, $2), ($3, $4)", invalid, []byte{0x00}, duplicate, []byte{0x01})
+	assert.NoError(t, err)
+
+	report, err = block.CheckSqlitePositions(db, &block.IntegrityOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, count+3, report.Blocks)
+	assert.Equal(t, 2, len(report.Issues))
+	for _, issue := range report.Issues {
+		assert.False(t, issue.Fixed)
+		switch *issue.RawPos {
+		case invalid:
+			assert.Equal(t, block.INTEGRITY_INVALID_POS, issue.Type)
+			assert.Equal(t, block.Pos{X: 100, Y: 100, Z: 100}, issue.Pos)
+		case duplicate:
+			assert.Equal(t, block.INTEGRITY_DUPLICATE_POS, issue.Type)
+			assert.Equal(t, block.Pos{X: 0, Y: 0, Z: 0}, issue.Pos)
+		default:
+			t.Errorf("unexpected issue: %v", issue)
+		}
+	}
+
+	// invalid options
+	_, err = block.CheckSqlitePositions(db, &block.IntegrityOptions{Action: block.INTEGRITY_ACTION_QUARANTINE})
+	assert.Error(t, err)
+	_, err = block.CheckIntegrity(repo, &block.IntegrityOptions{Action: "fix"})
+	assert.Error(t, err)
+
+	// quarantine
+	qfile, err := os.CreateTemp(os.TempDir(), "quarantine.sqlite")
+	assert.NoError(t, err)
+	qdb, err := sql.Open("sqlite3", "file:"+qfile.Name())
+	assert.NoError(t, err)
+	defer qdb.Close()
+	assert.NoError(t, block.MigrateBlockDB(qdb, types.DATABASE_SQLITE))
+	quarantine, err := block.NewBlockRepository(qdb, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+
+	opts := &block.IntegrityOptions{Action: block.INTEGRITY_ACTION_QUARANTINE, Quarantine: quarantine}
+	report, err = block.CheckSqlitePositions(db, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report.Issues))
+	assert.True(t, report.Issues[0].Fixed)
+	assert.True(t, report.Issues[1].Fixed)
+
+	r2, err := block.CheckIntegrity(repo, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(r2.Issues))
+	assert.Equal(t, block.INTEGRITY_DECODE_ERROR, r2.Issues[0].Type)
+	assert.Equal(t, block.Pos{X: 10, Y: 11, Z: 12}, r2.Issues[0].Pos)
+	assert.True(t, r2.Issues[0].Fixed)
+	report.Add(r2)
+	assert.Equal(t, 3, len(report.Issues))
+
+	// original blocks untouched, bad blocks moved
+	b, err := repo.GetByPos(0, 0, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+	assert.NotEqual(t, []byte{0x01}, b.Data)
+	b, err = repo.GetByPos(10, 11, 12)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	qcount, err := quarantine.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), qcount)
+
+	report, err = block.CheckIntegrity(repo, &block.IntegrityOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, count, report.Blocks)
+	assert.Equal(t, 0, len(report.Issues))
+}
+
+func TestCheckIntegrityLegacyVersion(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.NoError(t, block.MigrateBlockDB(db, types.DATABASE_SQLITE))
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+
+	legacy := &block.Block{PosX: 1, PosY: 2, PosZ: 3, Data: createLegacyMapblock(t, "default:stone")}
+	assert.NoError(t, repo.Update(legacy))
+
+	// valid v28 blocks are reported as ok and kept with the delete action
+	report, err := block.CheckIntegrity(repo, &block.IntegrityOptions{Action: block.INTEGRITY_ACTION_DELETE})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), report.Blocks)
+	assert.Equal(t, 0, len(report.Issues))
+
+	b, err := repo.GetByPos(1, 2, 3)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+	assert.Equal(t, legacy.Data, b.Data)
+}
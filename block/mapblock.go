@@ -0,0 +1,333 @@
+package block
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// NodesPerBlock is the amount of nodes in a 16x16x16 mapblock
+const NodesPerBlock = 4096
+
+const (
+	// oldest supported mapblock serialization version
+	MinMapblockVersion = 25
+	// newest supported mapblock serialization version (zstd compressed)
+	MaxMapblockVersion = 29
+)
+
+var zstdDecoder, _ = zstd.NewReader(nil)
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+// Mapblock is the decoded content of a Block.Data field
+// the format is described here: https://github.com/minetest/minetest/blob/master/doc/world_format.md
+//
+// Only the header, the name-id mapping and the node data are decoded, node-metadata,
+// static objects and node-timers are kept in their serialized form
+type Mapblock struct {
+	Version          uint8
+	Flags            uint8
+	LightingComplete uint16
+	Timestamp        uint32
+	// node-id to node-name mapping
+	Mapping map[uint16]string
+	// node data, indexed by NodeIndex()
+	Param0 []uint16
+	Param1 []uint8
+	Param2 []uint8
+
+	// serialized, compressed (version < 29) node metadata and static objects
+	metadataAndObjects []byte
+	// serialized node timers (and metadata/objects for version 29)
+	trailer []byte
+}
+
+// NodeIndex returns the index into the Param* slices of the node at the given
+// position relative to the mapblock origin (0 to 15 for each axis)
+func NodeIndex(x, y, z int) int {
+	return x + (y * 16) + (z * 256)
+}
+
+// GetNodeName returns the node-name at the given relative position
+func (m *Mapblock) GetNodeName(x, y, z int) string {
+	return m.Mapping[m.Param0[NodeIndex(x, y, z)]]
+}
+
+// ModName returns the mod-part of a node-name ("default:stone" -> "default"),
+// node-names without a mod-prefix (like "air" or "ignore") return an empty string
+func ModName(nodename string) string {
+	i := strings.Index(nodename, ":")
+	if i < 0 {
+		return ""
+	}
+	return nodename[:i]
+}
+
+// ParseMapblock decodes the raw data of a mapblock
+func ParseMapblock(data []byte) (*Mapblock, error) {
+	if len(data) < 1 {
+		return nil, errors.New("empty mapblock data")
+	}
+	m := &Mapblock{Version: data[0]}
+	if m.Version < MinMapblockVersion || m.Version > MaxMapblockVersion {
+		return nil, fmt.Errorf("unsupported mapblock version: %d", m.Version)
+	}
+
+	if m.Version >= 29 {
+		content, err := zstdDecoder.DecodeAll(data[1:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("zstd decompression failed: %v", err)
+		}
+		return m, m.parseV29(bytes.NewReader(content))
+	}
+
+	return m, m.parseLegacy(bytes.NewReader(data[1:]))
+}
+
+func (m *Mapblock) parseV29(r *bytes.Reader) error {
+	err := readAll(r, &m.Flags, &m.LightingComplete, &m.Timestamp)
+	if err != nil {
+		return fmt.Errorf("header: %v", err)
+	}
+	err = m.readMapping(r)
+	if err != nil {
+		return fmt.Errorf("name-id mapping: %v", err)
+	}
+	err = m.readNodeData(r)
+	if err != nil {
+		return fmt.Errorf("node data: %v", err)
+	}
+	m.trailer, err = io.ReadAll(r)
+	return err
+}
+
+func (m *Mapblock) parseLegacy(r *bytes.Reader) error {
+	err := readAll(r, &m.Flags)
+	if err == nil && m.Version >= 27 {
+		err = readAll(r, &m.LightingComplete)
+	}
+	if err != nil {
+		return fmt.Errorf("header: %v", err)
+	}
+
+	// the content and params widths precede the compressed node data
+	err = readNodeWidths(r)
+	if err != nil {
+		return fmt.Errorf("node data: %v", err)
+	}
+	nodedata, err := readZlib(r)
+	if err != nil {
+		return fmt.Errorf("node data: %v", err)
+	}
+	err = m.readParams(bytes.NewReader(nodedata))
+	if err != nil {
+		return fmt.Errorf("node data: %v", err)
+	}
+
+	// keep the compressed metadata and the static objects as-is
+	start := int(r.Size()) - r.Len()
+	_, err = readZlib(r)
+	if err != nil {
+		return fmt.Errorf("node metadata: %v", err)
+	}
+	err = skipStaticObjects(r)
+	if err != nil {
+		return fmt.Errorf("static objects: %v", err)
+	}
+	end := int(r.Size()) - r.Len()
+	buf := make([]byte, end-start)
+	_, err = r.ReadAt(buf, int64(start))
+	if err != nil {
+		return err
+	}
+	m.metadataAndObjects = buf
+
+	err = readAll(r, &m.Timestamp)
+	if err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	err = m.readMapping(r)
+	if err != nil {
+		return fmt.Errorf("name-id mapping: %v", err)
+	}
+	m.trailer, err = io.ReadAll(r)
+	return err
+}
+
+func (m *Mapblock) readMapping(r io.Reader) error {
+	var version uint8
+	var count uint16
+	err := readAll(r, &version, &count)
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		return fmt.Errorf("unsupported mapping version: %d", version)
+	}
+
+	m.Mapping = make(map[uint16]string, count)
+	for i := 0; i < int(count); i++ {
+		var id, namelen uint16
+		err = readAll(r, &id, &namelen)
+		if err != nil {
+			return err
+		}
+		name := make([]byte, namelen)
+		_, err = io.ReadFull(r, name)
+		if err != nil {
+			return err
+		}
+		m.Mapping[id] = string(name)
+	}
+	return nil
+}
+
+func (m *Mapblock) readNodeData(r io.Reader) error {
+	err := readNodeWidths(r)
+	if err != nil {
+		return err
+	}
+	return m.readParams(r)
+}
+
+func readNodeWidths(r io.Reader) error {
+	var contentWidth, paramsWidth uint8
+	err := readAll(r, &contentWidth, &paramsWidth)
+	if err != nil {
+		return err
+	}
+	if contentWidth != 2 || paramsWidth != 2 {
+		return fmt.Errorf("unsupported content/params width: %d/%d", contentWidth, paramsWidth)
+	}
+	return nil
+}
+
+func (m *Mapblock) readParams(r io.Reader) error {
+	m.Param0 = make([]uint16, NodesPerBlock)
+	m.Param1 = make([]uint8, NodesPerBlock)
+	m.Param2 = make([]uint8, NodesPerBlock)
+	return readAll(r, m.Param0, m.Param1, m.Param2)
+}
+
+// Serialize encodes the mapblock into its binary form with the same version it was parsed from
+func (m *Mapblock) Serialize() ([]byte, error) {
+	if len(m.Param0) != NodesPerBlock || len(m.Param1) != NodesPerBlock || len(m.Param2) != NodesPerBlock {
+		return nil, errors.New("invalid node data size")
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if m.Version >= 29 {
+		writeAll(buf, m.Flags, m.LightingComplete, m.Timestamp)
+		m.writeMapping(buf)
+		m.writeNodeData(buf)
+		buf.Write(m.trailer)
+		return append([]byte{m.Version}, zstdEncoder.EncodeAll(buf.Bytes(), nil)...), nil
+	}
+
+	writeAll(buf, m.Version, m.Flags)
+	if m.Version >= 27 {
+		writeAll(buf, m.LightingComplete)
+	}
+
+	// the content and params widths precede the compressed node data
+	writeAll(buf, uint8(2), uint8(2))
+	nodedata := bytes.NewBuffer([]byte{})
+	m.writeParams(nodedata)
+	w := zlib.NewWriter(buf)
+	_, err := w.Write(nodedata.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(m.metadataAndObjects)
+	writeAll(buf, m.Timestamp)
+	m.writeMapping(buf)
+	buf.Write(m.trailer)
+	return buf.Bytes(), nil
+}
+
+func (m *Mapblock) writeMapping(buf *bytes.Buffer) {
+	ids := make([]int, 0, len(m.Mapping))
+	for id := range m.Mapping {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	writeAll(buf, uint8(0), uint16(len(ids)))
+	for _, id := range ids {
+		name := m.Mapping[uint16(id)]
+		writeAll(buf, uint16(id), uint16(len(name)))
+		buf.WriteString(name)
+	}
+}
+
+func (m *Mapblock) writeNodeData(buf *bytes.Buffer) {
+	writeAll(buf, uint8(2), uint8(2))
+	m.writeParams(buf)
+}
+
+func (m *Mapblock) writeParams(buf *bytes.Buffer) {
+	writeAll(buf, m.Param0, m.Param1, m.Param2)
+}
+
+// reads a zlib stream to the end, leaves the reader positioned right after it
+func readZlib(r *bytes.Reader) ([]byte, error) {
+	z, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	return io.ReadAll(z)
+}
+
+// skips the static object list of a pre-29 mapblock
+func skipStaticObjects(r *bytes.Reader) error {
+	var version uint8
+	var count uint16
+	err := readAll(r, &version, &count)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		var objtype uint8
+		var pos [3]int32
+		var datalen uint16
+		err = readAll(r, &objtype, &pos, &datalen)
+		if err != nil {
+			return err
+		}
+		_, err = r.Seek(int64(datalen), io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readAll(r io.Reader, values ...any) error {
+	for _, v := range values {
+		err := binary.Read(r, binary.BigEndian, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAll(buf *bytes.Buffer, values ...any) {
+	for _, v := range values {
+		// writes to a bytes.Buffer don't fail
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
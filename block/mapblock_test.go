@@ -0,0 +1,141 @@
+package block_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"database/sql"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func openLegacyMap(t *testing.T) block.BlockRepository {
+	dbfile, err := os.CreateTemp(os.TempDir(), "map.sqlite")
+	assert.NoError(t, err)
+	assert.NoError(t, copyFileContents("testdata/map_legacy_column.sqlite", dbfile.Name()))
+
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	return repo
+}
+
+func TestParseMapblock(t *testing.T) {
+	repo := openLegacyMap(t)
+	defer repo.Close()
+
+	b, err := repo.GetByPos(0, 0, 0)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+
+	m, err := block.ParseMapblock(b.Data)
+	assert.NoError(t, err)
+	assert.NotNil(t, m)
+	assert.Equal(t, uint8(29), m.Version)
+	assert.Equal(t, block.NodesPerBlock, len(m.Param0))
+	assert.True(t, len(m.Mapping) > 0)
+	for _, id := range m.Param0 {
+		assert.NotEqual(t, "", m.Mapping[id])
+	}
+
+	// roundtrip
+	data, err := m.Serialize()
+	assert.NoError(t, err)
+	m2, err := block.ParseMapblock(data)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Mapping, m2.Mapping)
+	assert.Equal(t, m.Param0, m2.Param0)
+	assert.Equal(t, m.Param1, m2.Param1)
+	assert.Equal(t, m.Param2, m2.Param2)
+	assert.Equal(t, m.Timestamp, m2.Timestamp)
+}
+
+// creates a version 28 mapblock filled with a single node, laid out like the engine writes it
+// (MapBlock::serialize): version, flags, lighting_complete, content_width and params_width
+// uncompressed, followed by the zlib compressed node data and node metadata
+func createLegacyMapblock(t *testing.T, nodename string) []byte {
+	zlibData := func(data []byte) []byte {
+		buf := bytes.NewBuffer([]byte{})
+		w := zlib.NewWriter(buf)
+		_, err := w.Write(data)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	// param0 (all id 0), param1 and param2
+	nodedata := make([]byte, block.NodesPerBlock*4)
+
+	// version, flags, lighting_complete, content_width, params_width
+	buf := bytes.NewBuffer([]byte{28, 0x00, 0xFF, 0xFF, 2, 2})
+	buf.Write(zlibData(nodedata))
+	// empty metadata
+	buf.Write(zlibData([]byte{0}))
+	// static objects: version 0, one object
+	buf.Write([]byte{0, 0, 1, 7, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 3, 'a', 'b', 'c'})
+	// timestamp
+	binary.Write(buf, binary.BigEndian, uint32(1234))
+	// mapping
+	buf.Write([]byte{0, 0, 1, 0, 0})
+	binary.Write(buf, binary.BigEndian, uint16(len(nodename)))
+	buf.WriteString(nodename)
+	// node timers
+	buf.Write([]byte{10, 0, 0})
+	return buf.Bytes()
+}
+
+func TestParseMapblockLegacyVersion(t *testing.T) {
+	data := createLegacyMapblock(t, "default:stone")
+
+	m, err := block.ParseMapblock(data)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(28), m.Version)
+	assert.Equal(t, uint16(0xFFFF), m.LightingComplete)
+	assert.Equal(t, uint32(1234), m.Timestamp)
+	assert.Equal(t, map[uint16]string{0: "default:stone"}, m.Mapping)
+	assert.Equal(t, "default:stone", m.GetNodeName(15, 15, 15))
+
+	// roundtrip
+	data2, err := m.Serialize()
+	assert.NoError(t, err)
+	assert.Equal(t, data, data2)
+
+	// uncompressed widths, followed by the zlib header of the node data
+	assert.Equal(t, []byte{28, 0x00, 0xFF, 0xFF, 2, 2, 0x78}, data2[:7])
+}
+
+func TestParseMapblockLegacyWidths(t *testing.T) {
+	data := createLegacyMapblock(t, "default:stone")
+
+	// widths inside the compressed stream (as written by earlier versions of this package)
+	invalid := append([]byte{}, data[:4]...)
+	invalid = append(invalid, data[6:]...)
+	_, err := block.ParseMapblock(invalid)
+	assert.Error(t, err)
+
+	// unsupported widths
+	data[4] = 1
+	_, err = block.ParseMapblock(data)
+	assert.Error(t, err)
+}
+
+func TestParseMapblockInvalid(t *testing.T) {
+	_, err := block.ParseMapblock(nil)
+	assert.Error(t, err)
+
+	_, err = block.ParseMapblock([]byte{0x10})
+	assert.Error(t, err)
+
+	_, err = block.ParseMapblock([]byte{29, 0x01, 0x02})
+	assert.Error(t, err)
+}
+
+func TestModName(t *testing.T) {
+	assert.Equal(t, "default", block.ModName("default:stone"))
+	assert.Equal(t, "", block.ModName("air"))
+}
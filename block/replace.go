@@ -0,0 +1,140 @@
+package block
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReplaceOptions configures a node-replacement run
+type ReplaceOptions struct {
+	// node-name replacements (old-name -> new-name)
+	Replacements map[string]string
+	// optional area to restrict the replacement to
+	Area *Area
+	// only count the affected mapblocks and nodes, don't write anything back
+	DryRun bool
+	// optional position to resume a previous run from (exclusive), see ReplaceResult.LastPos
+	ResumeAfter *Pos
+	// optional progress callback, called after every written batch
+	Progress func(r *ReplaceResult)
+}
+
+// ReplaceResult contains the counters of a node-replacement run
+type ReplaceResult struct {
+	// number of processed mapblocks
+	Blocks int64 `json:"blocks"`
+	// number of mapblocks that could not be parsed
+	InvalidBlocks int64 `json:"invalid_blocks"`
+	// number of changed (or to be changed in dry-run mode) mapblocks
+	ChangedBlocks int64 `json:"changed_blocks"`
+	// number of replaced nodes per old node-name
+	ReplacedNodes map[string]int64 `json:"replaced_nodes"`
+	// last processed (and written) mapblock position
+	LastPos *Pos `json:"last_pos"`
+}
+
+// ReplaceNodes rewrites the name-id mapping of a single mapblock, returns the number of
+// replaced nodes per old node-name, the mapblock is only changed if the returned map is not empty
+func ReplaceNodes(m *Mapblock, replacements map[string]string) map[string]int64 {
+	counts := map[string]int64{}
+	ids := map[uint16]string{}
+	for id, name := range m.Mapping {
+		newname, found := replacements[name]
+		if found && newname != name {
+			ids[id] = name
+			m.Mapping[id] = newname
+		}
+	}
+	if len(ids) == 0 {
+		return counts
+	}
+
+	for _, id := range m.Param0 {
+		name, found := ids[id]
+		if found {
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+// ReplaceBatchSize is the number of mapblocks to process before the changes are written
+// and the progress is reported
+var ReplaceBatchSize = 1000
+
+var errBatchFull = errors.New("batch full")
+
+// Replace replaces the nodes in all (or only the area-restricted) mapblocks.
+// The changed mapblocks are written in batches after the iterator is closed to avoid
+// locking issues, the progress callback is called after each written batch
+func Replace(repo BlockRepository, opts *ReplaceOptions) (*ReplaceResult, error) {
+	result := &ReplaceResult{
+		ReplacedNodes: map[string]int64{},
+		LastPos:       opts.ResumeAfter,
+	}
+
+	for {
+		changed := []*Block{}
+		processed := 0
+		var lastpos *Pos
+
+		err := IterateArea(repo, opts.Area, result.LastPos, func(b *Block) error {
+			result.Blocks++
+			processed++
+			lastpos = &Pos{X: b.PosX, Y: b.PosY, Z: b.PosZ}
+
+			m, err := ParseMapblock(b.Data)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"pos": []int{b.PosX, b.PosY, b.PosZ},
+					"err": err,
+				}).Warn("invalid mapblock")
+				result.InvalidBlocks++
+			} else {
+				counts := ReplaceNodes(m, opts.Replacements)
+				if len(counts) > 0 {
+					for name, count := range counts {
+						result.ReplacedNodes[name] += count
+					}
+					result.ChangedBlocks++
+
+					b.Data, err = m.Serialize()
+					if err != nil {
+						return err
+					}
+					changed = append(changed, b)
+				}
+			}
+
+			if processed >= ReplaceBatchSize {
+				return errBatchFull
+			}
+			return nil
+		})
+		if err != nil && err != errBatchFull {
+			return result, err
+		}
+
+		if !opts.DryRun {
+			for _, b := range changed {
+				uerr := repo.Update(b)
+				if uerr != nil {
+					return result, uerr
+				}
+			}
+		}
+
+		if lastpos != nil {
+			result.LastPos = lastpos
+		}
+		if opts.Progress != nil {
+			opts.Progress(result)
+		}
+
+		if err != errBatchFull {
+			// all blocks processed
+			return result, nil
+		}
+	}
+}
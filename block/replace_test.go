@@ -0,0 +1,94 @@
+package block_test
+
+import (
+	"testing"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplace(t *testing.T) {
+	repo := openLegacyMap(t)
+	defer repo.Close()
+
+	oldSize := block.ReplaceBatchSize
+	block.ReplaceBatchSize = 50
+	defer func() { block.ReplaceBatchSize = oldSize }()
+
+	s, err := block.CollectStats(repo)
+	assert.NoError(t, err)
+	stone := s.Nodes["nc_terrain:stone"]
+	assert.True(t, stone > 0)
+
+	replacements := map[string]string{"nc_terrain:stone": "default:stone"}
+
+	// dry-run
+	progress_calls := 0
+	r, err := block.Replace(repo, &block.ReplaceOptions{
+		Replacements: replacements,
+		DryRun:       true,
+		Progress:     func(r *block.ReplaceResult) { progress_calls++ },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, s.Blocks, r.Blocks)
+	assert.Equal(t, stone, r.ReplacedNodes["nc_terrain:stone"])
+	assert.True(t, r.ChangedBlocks > 0)
+	assert.Equal(t, 7, progress_calls)
+
+	s, err = block.CollectStats(repo)
+	assert.NoError(t, err)
+	assert.Equal(t, stone, s.Nodes["nc_terrain:stone"])
+
+	// area restricted
+	area := block.NewArea(block.Pos{X: 0, Y: 0, Z: 0}, block.Pos{X: 1, Y: 1, Z: 1})
+	r, err = block.Replace(repo, &block.ReplaceOptions{
+		Replacements: replacements,
+		Area:         area,
+	})
+	assert.NoError(t, err)
+	assert.True(t, r.Blocks <= 8)
+
+	b, err := repo.GetByPos(0, 0, 0)
+	assert.NoError(t, err)
+	m, err := block.ParseMapblock(b.Data)
+	assert.NoError(t, err)
+	for _, name := range m.Mapping {
+		assert.NotEqual(t, "nc_terrain:stone", name)
+	}
+	replaced := r.ReplacedNodes["nc_terrain:stone"]
+
+	// replace the rest
+	r, err = block.Replace(repo, &block.ReplaceOptions{
+		Replacements: replacements,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, stone-replaced, r.ReplacedNodes["nc_terrain:stone"])
+	assert.NotNil(t, r.LastPos)
+
+	s, err = block.CollectStats(repo)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), s.Nodes["nc_terrain:stone"])
+	assert.Equal(t, stone, s.Nodes["default:stone"])
+
+	// resume after the last position: nothing to do
+	r, err = block.Replace(repo, &block.ReplaceOptions{
+		Replacements: replacements,
+		ResumeAfter:  r.LastPos,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), r.Blocks)
+}
+
+func TestParseArea(t *testing.T) {
+	a, err := block.ParseArea("10,-2,3:-1,5,0")
+	assert.NoError(t, err)
+	assert.Equal(t, block.Pos{X: -1, Y: -2, Z: 0}, a.Min)
+	assert.Equal(t, block.Pos{X: 10, Y: 5, Z: 3}, a.Max)
+	assert.True(t, a.Contains(0, 0, 0))
+	assert.False(t, a.Contains(11, 0, 0))
+
+	_, err = block.ParseArea("1,2,3")
+	assert.Error(t, err)
+	_, err = block.ParseArea("1,2,3:a,b,c")
+	assert.Error(t, err)
+}
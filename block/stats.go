@@ -0,0 +1,59 @@
+package block
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Stats contains the node-counts of all mapblocks in a map database
+type Stats struct {
+	// number of counted mapblocks
+	Blocks int64 `json:"blocks"`
+	// number of mapblocks that could not be parsed
+	InvalidBlocks int64 `json:"invalid_blocks"`
+	// number of nodes per node-name
+	Nodes map[string]int64 `json:"nodes"`
+	// number of nodes per mod-name, see ModName()
+	Mods map[string]int64 `json:"mods"`
+}
+
+// Add counts the nodes of the given mapblock
+func (s *Stats) Add(m *Mapblock) {
+	counts := map[uint16]int64{}
+	for _, id := range m.Param0 {
+		counts[id]++
+	}
+	for id, count := range counts {
+		name := m.Mapping[id]
+		s.Nodes[name] += count
+		s.Mods[ModName(name)] += count
+	}
+	s.Blocks++
+}
+
+// CollectStats iterates over all mapblocks in the repository and counts the nodes per name and mod
+func CollectStats(repo BlockRepository) (*Stats, error) {
+	s := &Stats{
+		Nodes: map[string]int64{},
+		Mods:  map[string]int64{},
+	}
+
+	ch, _, err := repo.Iterator(MinBlockPos-1, MinBlockPos-1, MinBlockPos-1)
+	if err != nil {
+		return nil, err
+	}
+
+	for b := range ch {
+		m, err := ParseMapblock(b.Data)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"pos": []int{b.PosX, b.PosY, b.PosZ},
+				"err": err,
+			}).Warn("invalid mapblock")
+			s.InvalidBlocks++
+			continue
+		}
+		s.Add(m)
+	}
+
+	return s, nil
+}
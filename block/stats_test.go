@@ -0,0 +1,30 @@
+package block_test
+
+import (
+	"testing"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectStats(t *testing.T) {
+	repo := openLegacyMap(t)
+	defer repo.Close()
+
+	count, err := repo.Count()
+	assert.NoError(t, err)
+
+	s, err := block.CollectStats(repo)
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+	assert.Equal(t, count, s.Blocks)
+	assert.Equal(t, int64(0), s.InvalidBlocks)
+
+	total := int64(0)
+	for _, c := range s.Nodes {
+		total += c
+	}
+	assert.Equal(t, count*block.NodesPerBlock, total)
+	assert.True(t, s.Nodes["air"] > 0)
+	assert.True(t, s.Mods[""] >= s.Nodes["air"])
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/minetest-go/mtdb"
+)
+
+func checkConfigCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	readonly := fs.Bool("read-only", false, "validate for read-only access (existing sqlite files)")
+	json_output := fs.Bool("json", false, "print the issues as json")
+	fs.Parse(args)
+
+	wc, err := mtdb.LoadConfig(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+
+	issues := mtdb.ValidateConfig(world_dir, wc, *readonly)
+	if *json_output {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(issues)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue.Error())
+		}
+	}
+
+	error_count := len(issues.Errors())
+	if error_count > 0 {
+		return fmt.Errorf("configuration invalid: %d error(s), %d warning(s)", error_count, len(issues.Warnings()))
+	}
+	if !*json_output {
+		fmt.Printf("configuration valid: %d warning(s)\n", len(issues.Warnings()))
+	}
+	return nil
+}
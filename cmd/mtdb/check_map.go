@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/sirupsen/logrus"
+)
+
+func checkMapCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("check-map", flag.ExitOnError)
+	action := fs.String("action", "", "action to take on bad mapblocks: 'delete' or 'quarantine' (default: report only)")
+	quarantine_file := fs.String("quarantine", "quarantine.sqlite", "sqlite file in the world directory for quarantined mapblocks")
+	fs.Parse(args)
+
+	opts := &block.IntegrityOptions{
+		Action: block.IntegrityAction(*action),
+		Progress: func(r *block.IntegrityReport) {
+			logrus.WithFields(logrus.Fields{
+				"blocks": r.Blocks,
+				"issues": len(r.Issues),
+			}).Info("check progress")
+		},
+	}
+	readonly := opts.Action == block.INTEGRITY_ACTION_NONE
+
+	if opts.Action == block.INTEGRITY_ACTION_QUARANTINE {
+		qdb, err := sql.Open("sqlite3", mtdb.SqliteURI(path.Join(world_dir, *quarantine_file), ""))
+		if err != nil {
+			return err
+		}
+		defer qdb.Close()
+		err = block.MigrateBlockDB(qdb, types.DATABASE_SQLITE)
+		if err != nil {
+			return err
+		}
+		opts.Quarantine, err = block.NewBlockRepository(qdb, types.DATABASE_SQLITE)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check the raw positions first, bad entries would otherwise show up at their wrapped position
+	report := &block.IntegrityReport{Issues: []*block.IntegrityIssue{}}
+	wrapped := map[block.Pos]bool{}
+	db, err := openSqliteMap(world_dir, readonly)
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		r, err := block.CheckSqlitePositions(db, opts)
+		db.Close()
+		if err != nil {
+			return err
+		}
+		// only the issues, the mapblocks are counted again below
+		report.Issues = append(report.Issues, r.Issues...)
+		for _, issue := range r.Issues {
+			if !issue.Fixed {
+				wrapped[issue.Pos] = true
+			}
+		}
+	}
+
+	var blocks block.BlockRepository
+	if readonly {
+		blocks, err = mtdb.NewReadOnlyBlockDB(world_dir, contextOptions())
+	} else {
+		blocks, err = mtdb.NewBlockDB(world_dir, contextOptions())
+	}
+	if err != nil {
+		return err
+	}
+	if blocks == nil {
+		return errors.New("no map database configured")
+	}
+	defer blocks.Close()
+
+	r, err := block.CheckIntegrity(blocks, opts)
+	if err != nil {
+		return err
+	}
+	report.Blocks += r.Blocks
+	for _, issue := range r.Issues {
+		if !wrapped[issue.Pos] {
+			// not already reported by the raw position check
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	unfixed := 0
+	for _, issue := range report.Issues {
+		if !issue.Fixed {
+			unfixed++
+		}
+	}
+	if unfixed > 0 {
+		return fmt.Errorf("%d bad mapblock(s) found", unfixed)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/auth"
+)
+
+func cleanupAccountsCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("cleanup-accounts", flag.ExitOnError)
+	inactive_days := fs.Int("inactive-days", 0, "remove accounts without a login in the given number of days")
+	lacks_priv := fs.String("lacks-priv", "", "only remove accounts without the given privilege")
+	mods := fs.String("mods", "", "comma-separated list of mods with storage entries keyed by the player name to remove")
+	dry_run := fs.Bool("dry-run", false, "only report the affected accounts and rows")
+	fs.Parse(args)
+
+	if *inactive_days <= 0 {
+		return errors.New("the -inactive-days flag is required")
+	}
+	before := int(time.Now().Add(-time.Duration(*inactive_days) * 24 * time.Hour).Unix())
+	opts := &mtdb.CleanupOptions{
+		Search: &auth.AuthSearch{LastLoginBefore: &before},
+		DryRun: *dry_run,
+	}
+	if *lacks_priv != "" {
+		opts.Search.LacksPrivileges = []string{*lacks_priv}
+	}
+	if *mods != "" {
+		opts.ModStorageMods = strings.Split(*mods, ",")
+	}
+
+	var ctx *mtdb.Context
+	var err error
+	if *dry_run {
+		ctx, err = mtdb.NewReadOnly(world_dir, contextOptions())
+	} else {
+		ctx, err = mtdb.New(world_dir, contextOptions())
+	}
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	report, err := ctx.CleanupAccounts(opts)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/sirupsen/logrus"
+)
+
+func convertMapCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("convert-map", flag.ExitOnError)
+	layout := fs.String("layout", "", "target layout of the sqlite map: 'pos' (legacy) or 'xyz'")
+	fs.Parse(args)
+
+	db, err := openSqliteMap(world_dir, false)
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("only sqlite3 maps can be converted")
+	}
+	defer db.Close()
+
+	current, err := block.GetSqliteLayout(db)
+	if err != nil {
+		return err
+	}
+	if *layout == "" {
+		fmt.Printf("current layout: %s\n", current)
+		return nil
+	}
+
+	r, err := block.ConvertSqliteLayout(db, block.SqliteLayout(*layout), func(converted, total int64) {
+		logrus.WithFields(logrus.Fields{
+			"converted": converted,
+			"total":     total,
+		}).Info("convert progress")
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// opens the sqlite map database of the world directly, returns nil if the map is not stored in sqlite
+func openSqliteMap(world_dir string, readonly bool) (*sql.DB, error) {
+	wc, err := mtdb.LoadConfig(world_dir, contextOptions())
+	if err != nil {
+		return nil, err
+	}
+	backend := wc[worldconfig.CONFIG_MAP_BACKEND]
+	if backend != "" && backend != worldconfig.BACKEND_SQLITE3 {
+		return nil, nil
+	}
+
+	filename := path.Join(world_dir, "map.sqlite")
+	_, err = os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("_timeout=%d", mtdb.DEFAULT_SQLITE_BUSY_TIMEOUT)
+	if readonly {
+		query += "&mode=ro"
+	}
+	return sql.Open("sqlite3", mtdb.SqliteURI(filename, query))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/minetest-go/mtdb"
+)
+
+func infoCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	json_output := fs.Bool("json", false, "print the diagnostics as json")
+	fs.Parse(args)
+
+	ctx, err := mtdb.NewReadOnly(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	d, err := ctx.Diagnostics()
+	if err != nil {
+		return err
+	}
+
+	if *json_output {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	for _, db := range d.Databases {
+		fmt.Printf("%s: %s, schema version %d/%d\n", db.Name, db.Backend, db.Schema.Current, db.Schema.Latest)
+		if db.Sqlite != nil {
+			fmt.Printf("  journal mode: %s, page size: %d, pages: %d, free pages: %d\n",
+				db.Sqlite.JournalMode, db.Sqlite.PageSize, db.Sqlite.PageCount, db.Sqlite.FreelistCount)
+		}
+		if db.Layout != "" {
+			fmt.Printf("  layout: %s\n", db.Layout)
+		}
+		if db.SharedWith != "" {
+			fmt.Printf("  tables: see %s\n", db.SharedWith)
+		}
+		for _, td := range db.Tables {
+			fmt.Printf("  table %s: %d rows", td.Name, td.Rows)
+			if td.Size > 0 {
+				fmt.Printf(", %d bytes, %d dead rows", td.Size, td.DeadRows)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
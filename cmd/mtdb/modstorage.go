@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/mod_storage"
+)
+
+func modStorageCommand(world_dir string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: mtdb modstorage export|import [flags]")
+	}
+	switch args[0] {
+	case "export":
+		return modStorageExport(world_dir, args[1:])
+	case "import":
+		return modStorageImport(world_dir, args[1:])
+	default:
+		return fmt.Errorf("unknown modstorage command: '%s', expected export or import", args[0])
+	}
+}
+
+func modStorageExport(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("modstorage export", flag.ExitOnError)
+	mods := fs.String("mods", "", "comma-separated list of mods to export (default: all)")
+	out := fs.String("out", "", "output file (default: stdout)")
+	fs.Parse(args)
+
+	ctx, err := mtdb.NewReadOnly(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	if ctx.ModStorage == nil {
+		return errors.New("no mod storage database configured")
+	}
+
+	modnames := []string{}
+	if *mods != "" {
+		modnames = strings.Split(*mods, ",")
+	}
+	doc, err := mod_storage.Export(ctx.ModStorage, modnames...)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func modStorageImport(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("modstorage import", flag.ExitOnError)
+	mode := fs.String("mode", string(mod_storage.IMPORT_MERGE), "import mode: 'merge' (keep other keys) or 'replace' (remove other keys of the imported mods)")
+	in := fs.String("in", "", "input file (default: stdin)")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	doc := &mod_storage.ExportDocument{}
+	err := json.NewDecoder(r).Decode(doc)
+	if err != nil {
+		return fmt.Errorf("invalid export document: %v", err)
+	}
+
+	ctx, err := mtdb.New(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	if ctx.ModStorage == nil {
+		return errors.New("no mod storage database configured")
+	}
+
+	result, err := mod_storage.Import(ctx.ModStorage, doc, mod_storage.ImportMode(*mode))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
@@ -21,8 +21,58 @@ var help = flag.Bool("help", false, "shows the help")
 var show_version = flag.Bool("version", false, "shows the version")
 var migrate = flag.Bool("migrate", false, "just migrates the database schemas and exit")
 var init_world = flag.Bool("init", false, "initialize world.mt with defaults if it does not exist")
+var minetest_conf = flag.String("minetest-conf", "", "optional minetest.conf to read additional settings from")
+
+// returns the context options, settings can be overridden with "MTDB_" environment variables
+func contextOptions() *mtdb.Options {
+	return &mtdb.Options{
+		MinetestConf: *minetest_conf,
+		EnvPrefix:    mtdb.DEFAULT_ENV_PREFIX,
+	}
+}
+
+type command struct {
+	name        string
+	description string
+	run         func(world_dir string, args []string) error
+}
+
+// subcommands, invoked with "mtdb [flags] <command> [command-flags]"
+var commands = []*command{
+	{name: "info", description: "shows the backend, schema and table diagnostics of all databases", run: infoCommand},
+	{name: "stats", description: "counts the nodes in the map per name and mod", run: statsCommand},
+	{name: "replace", description: "replaces nodes in the map by name", run: replaceCommand},
+	{name: "render", description: "renders top-down png tiles of the map", run: renderCommand},
+	{name: "check-map", description: "checks the map for corrupt and out-of-range mapblocks", run: checkMapCommand},
+	{name: "convert-map", description: "converts the sqlite map between the legacy pos and the x,y,z layout", run: convertMapCommand},
+	{name: "modstorage", description: "exports or imports mod storage entries as json (export|import)", run: modStorageCommand},
+	{name: "cleanup-accounts", description: "removes inactive accounts from all databases", run: cleanupAccountsCommand},
+	{name: "rename-player", description: "renames a player in all databases", run: renamePlayerCommand},
+	{name: "privs", description: "renames or revokes a privilege for all users (rename|revoke-all)", run: privsCommand},
+	{name: "check-config", description: "validates the database configuration of the world", run: checkConfigCommand},
+}
+
+func getCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: mtdb [flags] [command] [command-flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
 	if *help {
@@ -50,7 +100,22 @@ func main() {
 		}
 	}
 
-	ctx, err := mtdb.New(wd)
+	if flag.NArg() > 0 {
+		cmd := getCommand(flag.Arg(0))
+		if cmd == nil {
+			fmt.Printf("unknown command: '%s'\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(1)
+		}
+		err = cmd.run(wd, flag.Args()[1:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ctx, err := mtdb.New(wd, contextOptions())
 	if err != nil {
 		panic(err)
 	}
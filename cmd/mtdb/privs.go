@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/minetest-go/mtdb"
+)
+
+func privsCommand(world_dir string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: mtdb privs rename <old> <new> | revoke-all <priv>")
+	}
+
+	ctx, err := mtdb.New(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+	if ctx.Privs == nil {
+		return errors.New("no auth database configured")
+	}
+
+	switch args[0] {
+	case "rename":
+		if len(args) != 3 {
+			return errors.New("usage: mtdb privs rename <old> <new>")
+		}
+		count, err := ctx.Privs.RenamePriv(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("renamed privilege '%s' to '%s' for %d users\n", args[1], args[2], count)
+	case "revoke-all":
+		if len(args) != 2 {
+			return errors.New("usage: mtdb privs revoke-all <priv>")
+		}
+		count, err := ctx.Privs.RevokeFromAll(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("revoked privilege '%s' from %d users\n", args[1], count)
+	default:
+		return fmt.Errorf("unknown privs command: '%s', expected rename or revoke-all", args[0])
+	}
+	return nil
+}
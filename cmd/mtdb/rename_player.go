@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/minetest-go/mtdb"
+)
+
+func renamePlayerCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("rename-player", flag.ExitOnError)
+	mods := fs.String("mods", "", "comma-separated list of mods with storage entries keyed by the player name to rename")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mtdb rename-player [flags] <oldname> <newname>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("old and new player name required")
+	}
+	modnames := []string{}
+	if *mods != "" {
+		modnames = strings.Split(*mods, ",")
+	}
+
+	ctx, err := mtdb.New(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	err = ctx.RenamePlayer(fs.Arg(0), fs.Arg(1), modnames...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("renamed player '%s' to '%s'\n", fs.Arg(0), fs.Arg(1))
+	return nil
+}
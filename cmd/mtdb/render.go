@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/render"
+	"github.com/sirupsen/logrus"
+)
+
+func floorDiv(a, b int) int {
+	return int(math.Floor(float64(a) / float64(b)))
+}
+
+func renderCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	colors_file := fs.String("colors", "colors.txt", "node color table in the minetestmapper format")
+	area_str := fs.String("area", "", "mapblock area to render x1,y1,z1:x2,y2,z2")
+	tilesize := fs.Int("tile-size", 16, "tile size in mapblocks")
+	out_dir := fs.String("out", "tiles", "output directory for the <x>_<z>.png tiles")
+	fs.Parse(args)
+
+	if *area_str == "" {
+		fs.Usage()
+		return errors.New("no area given")
+	}
+	area, err := block.ParseArea(*area_str)
+	if err != nil {
+		return err
+	}
+	if *tilesize < 1 {
+		return fmt.Errorf("invalid tile size: %d", *tilesize)
+	}
+
+	colors, err := render.LoadColors(*colors_file)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(*out_dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := mtdb.NewReadOnlyBlockDB(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	if blocks == nil {
+		return errors.New("no map database configured")
+	}
+	defer blocks.Close()
+
+	r := render.NewRenderer(blocks, colors)
+	for tz := floorDiv(area.Min.Z, *tilesize); tz <= floorDiv(area.Max.Z, *tilesize); tz++ {
+		for tx := floorDiv(area.Min.X, *tilesize); tx <= floorDiv(area.Max.X, *tilesize); tx++ {
+			filename := path.Join(*out_dir, fmt.Sprintf("%d_%d.png", tx, tz))
+			f, err := os.Create(filename)
+			if err != nil {
+				return err
+			}
+			err = r.RenderPNG(render.TileArea(tx, tz, *tilesize, area.Min.Y, area.Max.Y), f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			logrus.WithField("filename", filename).Info("tile rendered")
+		}
+	}
+
+	return nil
+}
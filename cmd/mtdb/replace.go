@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/sirupsen/logrus"
+)
+
+func replaceCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("replace", flag.ExitOnError)
+	dry_run := fs.Bool("dry-run", false, "only count the affected mapblocks and nodes")
+	area_str := fs.String("area", "", "restrict the replacement to the mapblock area x1,y1,z1:x2,y2,z2")
+	resume_str := fs.String("resume", "", "resume after the given mapblock position x,y,z")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mtdb replace [flags] <oldname>=<newname> ...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts := &block.ReplaceOptions{
+		Replacements: map[string]string{},
+		DryRun:       *dry_run,
+		Progress: func(r *block.ReplaceResult) {
+			logrus.WithFields(logrus.Fields{
+				"blocks":         r.Blocks,
+				"changed_blocks": r.ChangedBlocks,
+				"last_pos":       r.LastPos,
+			}).Info("replace progress")
+		},
+	}
+
+	for _, arg := range fs.Args() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid replacement: '%s', expected <oldname>=<newname>", arg)
+		}
+		opts.Replacements[parts[0]] = parts[1]
+	}
+	if len(opts.Replacements) == 0 {
+		fs.Usage()
+		return errors.New("no replacements given")
+	}
+
+	var err error
+	if *area_str != "" {
+		opts.Area, err = block.ParseArea(*area_str)
+		if err != nil {
+			return err
+		}
+	}
+	if *resume_str != "" {
+		opts.ResumeAfter, err = block.ParsePos(*resume_str)
+		if err != nil {
+			return err
+		}
+	}
+
+	blocks, err := mtdb.NewBlockDB(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	if blocks == nil {
+		return errors.New("no map database configured")
+	}
+	defer blocks.Close()
+
+	r, err := block.Replace(blocks, opts)
+	if err != nil && r.LastPos != nil {
+		err = fmt.Errorf("%v, resume with -resume %s", err, r.LastPos)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+)
+
+func statsCommand(world_dir string, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.Parse(args)
+
+	blocks, err := mtdb.NewReadOnlyBlockDB(world_dir, contextOptions())
+	if err != nil {
+		return err
+	}
+	if blocks == nil {
+		return errors.New("no map database configured")
+	}
+	defer blocks.Close()
+
+	s, err := block.CollectStats(blocks)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
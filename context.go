@@ -2,8 +2,12 @@ package mtdb
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"path"
+	"strings"
 
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -12,6 +16,7 @@ import (
 	"github.com/minetest-go/mtdb/block"
 	"github.com/minetest-go/mtdb/mod_storage"
 	"github.com/minetest-go/mtdb/player"
+	"github.com/minetest-go/mtdb/schema"
 	"github.com/minetest-go/mtdb/types"
 	"github.com/minetest-go/mtdb/wal"
 	"github.com/minetest-go/mtdb/worldconfig"
@@ -26,21 +31,53 @@ type Context struct {
 	PlayerMetadata *player.PlayerMetadataRepository
 	Blocks         block.BlockRepository
 	ModStorage     mod_storage.ModStorageRepository
-	open_databases []*sql.DB
+	ReadOnly       bool
+	databases      []*contextDatabase
 }
 
 // closes all database connections
 func (ctx *Context) Close() {
-	for _, db := range ctx.open_databases {
-		db.Close()
+	for _, cdb := range ctx.databases {
+		cdb.db.Close()
 	}
 }
 
+func (ctx *Context) addDatabase(name string, dbtype types.DatabaseType, db *sql.DB, migrations *schema.Set, pg_connection string) {
+	ctx.databases = append(ctx.databases, &contextDatabase{name: name, dbtype: dbtype, db: db, migrations: migrations, pg_connection: pg_connection})
+}
+
 type connectMigrateOpts struct {
 	Type             types.DatabaseType
 	SQliteConnection string
 	PSQLConnection   string
 	MigrateFn        func(*sql.DB, types.DatabaseType) error
+	ReadOnly         bool
+	Connection       *ConnectionOptions
+}
+
+// adds the read-only session default to a postgres connection string (key/value or url format)
+func readOnlyPostgresConnection(conn string) (string, error) {
+	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
+		u, err := url.Parse(conn)
+		if err != nil {
+			return "", fmt.Errorf("invalid postgres connection url: %v", err)
+		}
+		q := u.Query()
+		q.Set("default_transaction_read_only", "on")
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return conn + " default_transaction_read_only=on", nil
+}
+
+// SqliteURI returns the "file:" uri of the sqlite database with the given query, the path segments are escaped
+func SqliteURI(filename, query string) string {
+	segments := strings.Split(filename, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := &url.URL{Scheme: "file", Opaque: strings.Join(segments, "/"), RawQuery: query}
+	return u.String()
 }
 
 // connects to the configured database and migrates the schema
@@ -52,6 +89,7 @@ func connectAndMigrate(opts *connectMigrateOpts) (*sql.DB, error) {
 		"db_type":     opts.Type,
 		"sqlite_conn": opts.SQliteConnection,
 		"pg_conn":     opts.PSQLConnection,
+		"read_only":   opts.ReadOnly,
 	}).Info("Connecting and migrating")
 
 	switch opts.Type {
@@ -62,7 +100,11 @@ func connectAndMigrate(opts *connectMigrateOpts) (*sql.DB, error) {
 		dbtype = "postgres"
 	default:
 		// default to sqlite
-		datasource = fmt.Sprintf("%s?_timeout=15000&_journal=WAL&_sync=NORMAL&_cache=shared", opts.SQliteConnection)
+		timeout, sync, cache, err := opts.Connection.sqliteParams()
+		if err != nil {
+			return nil, err
+		}
+		datasource = SqliteURI(opts.SQliteConnection, fmt.Sprintf("_timeout=%d&_journal=WAL&_sync=%s&_cache=%s", timeout, sync, cache))
 		dbtype = "sqlite3"
 	}
 
@@ -71,10 +113,15 @@ func connectAndMigrate(opts *connectMigrateOpts) (*sql.DB, error) {
 		return nil, nil
 	}
 
+	if opts.ReadOnly {
+		return connectReadOnly(opts, dbtype, datasource)
+	}
+
 	db, err := sql.Open(dbtype, datasource)
 	if err != nil {
 		return nil, err
 	}
+	opts.Connection.apply(db)
 
 	if opts.Type == types.DATABASE_SQLITE {
 		// enable wal on sqlite databases
@@ -92,32 +139,94 @@ func connectAndMigrate(opts *connectMigrateOpts) (*sql.DB, error) {
 	return db, nil
 }
 
-// parses the "world.mt" file in the world-dir and creates a new context
-func New(world_dir string) (*Context, error) {
+// connects to the configured database without any writes or migrations
+func connectReadOnly(opts *connectMigrateOpts, dbtype, datasource string) (*sql.DB, error) {
+	var err error
+	if opts.Type == types.DATABASE_POSTGRES {
+		datasource, err = readOnlyPostgresConnection(datasource)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_, err = os.Stat(opts.SQliteConnection)
+		if errors.Is(err, os.ErrNotExist) {
+			// nothing to read from, reported as a validation warning
+			logrus.WithField("filename", opts.SQliteConnection).Debug("Database file not found in read-only mode")
+			return nil, nil
+		}
+		timeout, _, cache, err := opts.Connection.sqliteParams()
+		if err != nil {
+			return nil, err
+		}
+		datasource = SqliteURI(opts.SQliteConnection, fmt.Sprintf("mode=ro&_timeout=%d&_cache=%s", timeout, cache))
+	}
+
+	db, err := sql.Open(dbtype, datasource)
+	if err != nil {
+		return nil, err
+	}
+	opts.Connection.apply(db)
+	return db, nil
+}
+
+// parses the "world.mt" file in the world-dir and creates a new context with optional options
+func New(world_dir string, opts ...*Options) (*Context, error) {
 	wc, err := worldconfig.Parse(path.Join(world_dir, "world.mt"))
 	if err != nil {
 		return nil, err
 	}
 
-	return NewWithConfig(world_dir, wc)
+	return NewWithConfig(world_dir, wc, opts...)
 }
 
-// creates the database context with the given config in map form
-func NewWithConfig(world_dir string, wc map[string]string) (*Context, error) {
+// parses the "world.mt" file in the world-dir and creates a new read-only context,
+// the databases are neither migrated nor modified, all write operations return types.ErrReadOnly
+func NewReadOnly(world_dir string, opts ...*Options) (*Context, error) {
+	wc, err := worldconfig.Parse(path.Join(world_dir, "world.mt"))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReadOnlyWithConfig(world_dir, wc, opts...)
+}
+
+// creates the database context with the given config in map form and optional connection options
+func NewWithConfig(world_dir string, wc map[string]string, opts ...*Options) (*Context, error) {
+	return newContext(world_dir, wc, false, opts)
+}
+
+// creates the read-only database context with the given config in map form and optional connection options
+func NewReadOnlyWithConfig(world_dir string, wc map[string]string, opts ...*Options) (*Context, error) {
+	return newContext(world_dir, wc, true, opts)
+}
+
+func newContext(world_dir string, wc map[string]string, readonly bool, opts_list []*Options) (*Context, error) {
+	opts := getOptions(opts_list)
+	wc, err := opts.resolveConfig(wc)
+	if err != nil {
+		return nil, err
+	}
+	err = checkConfig(world_dir, wc, readonly, databaseConfigs)
+	if err != nil {
+		return nil, err
+	}
 
 	logrus.WithFields(logrus.Fields{
 		"world_dir": world_dir,
 		"world.mt":  wc,
+		"read_only": readonly,
 	}).Debug("Creating new DB context")
-	ctx := &Context{}
+	ctx := &Context{ReadOnly: readonly}
 
-	// map
-	dbtype := types.DatabaseType(wc[worldconfig.CONFIG_MAP_BACKEND])
+	// map, skipped if the backend is not supported
+	dbtype := mapDatabaseConfig.contextBackend(wc)
 	map_db, err := connectAndMigrate(&connectMigrateOpts{
 		Type:             dbtype,
 		SQliteConnection: path.Join(world_dir, "map.sqlite"),
 		PSQLConnection:   wc[worldconfig.CONFIG_PSQL_MAP_CONNECTION],
-		MigrateFn:        block.MigrateBlockDB,
+		MigrateFn:        opts.blockMigrateFn(),
+		ReadOnly:         readonly,
+		Connection:       opts.Map,
 	})
 	if err != nil {
 		return nil, err
@@ -130,84 +239,129 @@ func NewWithConfig(world_dir string, wc map[string]string) (*Context, error) {
 		if ctx.Blocks == nil {
 			return nil, fmt.Errorf("invalid repository dbtype: %v", dbtype)
 		}
-		ctx.open_databases = append(ctx.open_databases, map_db)
+		if readonly {
+			ctx.Blocks = block.NewReadOnlyBlockRepository(ctx.Blocks)
+		}
+		ctx.addDatabase(DATABASE_MAP, dbtype, map_db, block.Migrations, wc[worldconfig.CONFIG_PSQL_MAP_CONNECTION])
 	}
 
 	// auth/privs
-	dbtype = types.DatabaseType(wc[worldconfig.CONFIG_AUTH_BACKEND])
+	dbtype = authDatabaseConfig.contextBackend(wc)
 	auth_db, err := connectAndMigrate(&connectMigrateOpts{
 		Type:             dbtype,
 		SQliteConnection: path.Join(world_dir, "auth.sqlite"),
 		PSQLConnection:   wc[worldconfig.CONFIG_PSQL_AUTH_CONNECTION],
 		MigrateFn:        auth.MigrateAuthDB,
+		ReadOnly:         readonly,
+		Connection:       opts.Auth,
 	})
 	if err != nil {
 		return nil, err
 	}
 	if auth_db != nil {
 		ctx.Auth = auth.NewAuthRepository(auth_db, dbtype)
+		ctx.Auth.SetReadOnly(readonly)
 		ctx.Privs = auth.NewPrivilegeRepository(auth_db, dbtype)
-		ctx.open_databases = append(ctx.open_databases, auth_db)
+		ctx.Privs.SetReadOnly(readonly)
+		ctx.addDatabase(DATABASE_AUTH, dbtype, auth_db, auth.Migrations, wc[worldconfig.CONFIG_PSQL_AUTH_CONNECTION])
 	}
 
 	// mod storage
-	dbtype = types.DatabaseType(wc[worldconfig.CONFIG_STORAGE_BACKEND])
+	dbtype = modStorageDatabaseConfig.contextBackend(wc)
 	mod_storage_db, err := connectAndMigrate(&connectMigrateOpts{
 		Type:             dbtype,
 		SQliteConnection: path.Join(world_dir, "mod_storage.sqlite"),
 		PSQLConnection:   wc[worldconfig.CONFIG_PSQL_MOD_STORAGE_CONNECTION],
 		MigrateFn:        mod_storage.MigrateModStorageDB,
+		ReadOnly:         readonly,
+		Connection:       opts.ModStorage,
 	})
 	if err != nil {
 		return nil, err
 	}
 	if mod_storage_db != nil {
 		ctx.ModStorage = mod_storage.NewModStorageRepository(mod_storage_db, dbtype)
-		ctx.open_databases = append(ctx.open_databases, mod_storage_db)
+		if readonly && ctx.ModStorage != nil {
+			ctx.ModStorage = mod_storage.NewReadOnlyModStorageRepository(ctx.ModStorage)
+		} else if opts.ModStorageAuditActor != "" {
+			err = mod_storage.MigrateModStorageAuditDB(mod_storage_db, dbtype)
+			if err != nil {
+				return nil, err
+			}
+			ctx.ModStorage = mod_storage.NewAuditRepository(mod_storage_db, dbtype, opts.ModStorageAuditActor)
+		}
+		ctx.addDatabase(DATABASE_MOD_STORAGE, dbtype, mod_storage_db, mod_storage.Migrations, wc[worldconfig.CONFIG_PSQL_MOD_STORAGE_CONNECTION])
 	}
 
 	// players
-	dbtype = types.DatabaseType(wc[worldconfig.CONFIG_PLAYER_BACKEND])
+	dbtype = playerDatabaseConfig.contextBackend(wc)
 	player_db, err := connectAndMigrate(&connectMigrateOpts{
 		Type:             dbtype,
 		SQliteConnection: path.Join(world_dir, "players.sqlite"),
 		PSQLConnection:   wc[worldconfig.CONFIG_PSQL_PLAYER_CONNECTION],
 		MigrateFn:        player.MigratePlayerDB,
+		ReadOnly:         readonly,
+		Connection:       opts.Player,
 	})
 	if err != nil {
 		return nil, err
 	}
 	if player_db != nil {
 		ctx.Player = player.NewPlayerRepository(player_db, dbtype)
+		ctx.Player.SetReadOnly(readonly)
 		ctx.PlayerMetadata = player.NewPlayerMetadataRepository(player_db, dbtype)
-		ctx.open_databases = append(ctx.open_databases, player_db)
+		ctx.PlayerMetadata.SetReadOnly(readonly)
+		ctx.addDatabase(DATABASE_PLAYER, dbtype, player_db, player.Migrations, wc[worldconfig.CONFIG_PSQL_PLAYER_CONNECTION])
 	}
 
 	return ctx, nil
 }
 
 // creates just the connection to the block-repository
-func NewBlockDB(world_dir string) (block.BlockRepository, error) {
-	logrus.WithFields(logrus.Fields{"world_dir": world_dir}).Debug("Creating new Block-DB")
+func NewBlockDB(world_dir string, opts ...*Options) (block.BlockRepository, error) {
+	return newBlockDB(world_dir, false, getOptions(opts))
+}
 
-	wc, err := worldconfig.Parse(path.Join(world_dir, "world.mt"))
+// creates just the read-only connection to the block-repository
+func NewReadOnlyBlockDB(world_dir string, opts ...*Options) (block.BlockRepository, error) {
+	return newBlockDB(world_dir, true, getOptions(opts))
+}
+
+func newBlockDB(world_dir string, readonly bool, opts *Options) (block.BlockRepository, error) {
+	logrus.WithFields(logrus.Fields{"world_dir": world_dir, "read_only": readonly}).Debug("Creating new Block-DB")
+
+	wc, err := LoadConfig(world_dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = checkConfig(world_dir, wc, readonly, []*databaseConfig{mapDatabaseConfig})
 	if err != nil {
 		return nil, err
 	}
 
 	// map
 	dbtype := types.DatabaseType(wc[worldconfig.CONFIG_MAP_BACKEND])
+	if !mapDatabaseConfig.supportedBackend(wc) {
+		// not supported, nothing to open
+		return nil, nil
+	}
 	map_db, err := connectAndMigrate(&connectMigrateOpts{
 		Type:             dbtype,
 		SQliteConnection: path.Join(world_dir, "map.sqlite"),
 		PSQLConnection:   wc[worldconfig.CONFIG_PSQL_MAP_CONNECTION],
-		MigrateFn:        block.MigrateBlockDB,
+		MigrateFn:        opts.blockMigrateFn(),
+		ReadOnly:         readonly,
+		Connection:       opts.Map,
 	})
 	if err != nil {
 		return nil, err
 	}
-	if map_db != nil {
-		return block.NewBlockRepository(map_db, dbtype)
+	if map_db == nil {
+		return nil, nil
+	}
+	repo, err := block.NewBlockRepository(map_db, dbtype)
+	if err != nil || repo == nil || !readonly {
+		return repo, err
 	}
-	return nil, nil
+	return block.NewReadOnlyBlockRepository(repo), nil
 }
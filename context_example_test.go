@@ -1,9 +1,12 @@
 package mtdb_test
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/auth"
+	"github.com/minetest-go/mtdb/types"
 )
 
 func ExampleContext() {
@@ -54,3 +57,22 @@ func ExampleNewBlockDB() {
 	// use the github.com/minetest-go/mapparser project to parse the actual content
 	fmt.Printf("Mapblock content: %s\n", block.Data)
 }
+
+func ExampleNewReadOnly() {
+	// open the databases of a running server without migrating or modifying them
+	ctx, err := mtdb.NewReadOnly("/my-world-dir")
+	if err != nil {
+		panic(err)
+	}
+	defer ctx.Close()
+
+	count, err := ctx.Auth.Count(&auth.AuthSearch{})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Registered players: %d\n", count)
+
+	// write operations are rejected
+	err = ctx.Auth.Delete(1)
+	fmt.Println(errors.Is(err, types.ErrReadOnly))
+}
@@ -10,6 +10,9 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/auth"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -121,4 +124,112 @@ pgsql_player_connection = ` + connStr + `
 	//assert.NotNil(t, repos.ModStorage)
 
 	repoSmokeTests(t, repos)
+
+	d, err := repos.Diagnostics()
+	assert.NoError(t, err)
+	// mod_storage defaults to sqlite
+	assert.Equal(t, 4, len(d.Databases))
+	assert.Nil(t, d.Databases[0].Sqlite)
+	assert.True(t, len(d.Databases[0].Tables) > 0)
+	// same postgres database, the tables are only listed once
+	assert.Equal(t, "", d.Databases[0].SharedWith)
+	assert.Equal(t, mtdb.DATABASE_AUTH, d.Databases[1].Name)
+	assert.Equal(t, mtdb.DATABASE_MAP, d.Databases[1].SharedWith)
+	assert.Equal(t, 0, len(d.Databases[1].Tables))
+	assert.Equal(t, 0, len(d.Databases[1].Schema.Pending))
+}
+
+func TestNewReadOnly(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+	contents := `
+backend = sqlite3
+auth_backend = sqlite3
+player_backend = sqlite3
+mod_storage_backend = sqlite3
+	`
+	err = os.WriteFile(path.Join(tmpdir, "world.mt"), []byte(contents), 0644)
+	assert.NoError(t, err)
+
+	// auth database in "delete" journal mode
+	authdb, err := os.ReadFile("wal/testdata/auth.sqlite")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path.Join(tmpdir, "auth.sqlite"), authdb, 0644))
+
+	repos, err := mtdb.NewReadOnly(tmpdir)
+	assert.NoError(t, err)
+	assert.NotNil(t, repos)
+	defer repos.Close()
+	assert.True(t, repos.ReadOnly)
+
+	// only the existing auth database is opened
+	assert.NotNil(t, repos.Auth)
+	assert.NotNil(t, repos.Privs)
+	assert.Nil(t, repos.Blocks)
+	assert.Nil(t, repos.Player)
+	assert.Nil(t, repos.ModStorage)
+	_, err = os.Stat(path.Join(tmpdir, "map.sqlite"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	entry, err := repos.Auth.GetByUsername("test")
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	assert.ErrorIs(t, repos.Auth.Update(entry), types.ErrReadOnly)
+	assert.ErrorIs(t, repos.Auth.Delete(*entry.ID), types.ErrReadOnly)
+	assert.ErrorIs(t, repos.Privs.Create(&auth.PrivilegeEntry{ID: *entry.ID, Privilege: "x"}), types.ErrReadOnly)
+
+	// journal mode unchanged
+	_, err = os.Stat(path.Join(tmpdir, "auth.sqlite-wal"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewSpecialCharacterPath(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+	world_dir := path.Join(tmpdir, "my world?#%20")
+	assert.NoError(t, os.Mkdir(world_dir, 0755))
+	err = os.WriteFile(path.Join(world_dir, "world.mt"), []byte("backend = sqlite3\nauth_backend = sqlite3"), 0644)
+	assert.NoError(t, err)
+
+	repos, err := mtdb.New(world_dir)
+	assert.NoError(t, err)
+	assert.NoError(t, repos.Auth.Create(&auth.AuthEntry{Name: "test", Password: "x"}))
+	repos.Close()
+
+	// created in the world dir
+	_, err = os.Stat(path.Join(world_dir, "auth.sqlite"))
+	assert.NoError(t, err)
+
+	ro, err := mtdb.NewReadOnly(world_dir)
+	assert.NoError(t, err)
+	defer ro.Close()
+	entry, err := ro.Auth.GetByUsername("test")
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+}
+
+func TestNewReadOnlyBlockDB(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+	err = os.WriteFile(path.Join(tmpdir, "world.mt"), []byte("backend = sqlite3"), 0644)
+	assert.NoError(t, err)
+
+	// create and migrate
+	rw, err := mtdb.NewBlockDB(tmpdir)
+	assert.NoError(t, err)
+	assert.NoError(t, rw.Update(&block.Block{PosX: 1, PosY: 2, PosZ: 3, Data: []byte{0x01}}))
+	assert.NoError(t, rw.Close())
+
+	ro, err := mtdb.NewReadOnlyBlockDB(tmpdir)
+	assert.NoError(t, err)
+	assert.NotNil(t, ro)
+	defer ro.Close()
+
+	b, err := ro.GetByPos(1, 2, 3)
+	assert.NoError(t, err)
+	assert.NotNil(t, b)
+	assert.ErrorIs(t, ro.Update(b), types.ErrReadOnly)
+	assert.ErrorIs(t, ro.Delete(1, 2, 3), types.ErrReadOnly)
+	assert.ErrorIs(t, ro.Vacuum(), types.ErrReadOnly)
 }
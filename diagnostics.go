@@ -0,0 +1,198 @@
+package mtdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/schema"
+	"github.com/minetest-go/mtdb/types"
+)
+
+// an opened database of the context
+type contextDatabase struct {
+	name       string
+	dbtype     types.DatabaseType
+	db         *sql.DB
+	migrations *schema.Set
+	// postgres connection string, logical databases sharing a connection share their tables
+	pg_connection string
+}
+
+// Diagnostics contains the state of all opened databases of a context
+type Diagnostics struct {
+	ReadOnly  bool                   `json:"read_only"`
+	Databases []*DatabaseDiagnostics `json:"databases"`
+}
+
+// DatabaseDiagnostics contains the state of a single database
+type DatabaseDiagnostics struct {
+	// database name, see DATABASE_*
+	Name    string             `json:"name"`
+	Backend types.DatabaseType `json:"backend"`
+	// schema migration state
+	Schema *schema.Status      `json:"schema"`
+	Tables []*TableDiagnostics `json:"tables"`
+	// name of the database the tables are listed in if the same postgres database is shared
+	SharedWith string             `json:"shared_with,omitempty"`
+	Sqlite     *SqliteDiagnostics `json:"sqlite,omitempty"`
+	// sqlite map table layout
+	Layout block.SqliteLayout `json:"layout,omitempty"`
+}
+
+// SqliteDiagnostics contains the sqlite specific database state
+type SqliteDiagnostics struct {
+	JournalMode   string `json:"journal_mode"`
+	PageSize      int64  `json:"page_size"`
+	PageCount     int64  `json:"page_count"`
+	FreelistCount int64  `json:"freelist_count"`
+}
+
+// TableDiagnostics contains the state of a single table
+type TableDiagnostics struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	// postgres only: total size in bytes, including indexes and toast data
+	Size int64 `json:"size,omitempty"`
+	// postgres only: number of dead rows (bloat) not yet reclaimed by vacuum
+	DeadRows int64 `json:"dead_rows,omitempty"`
+}
+
+// Diagnostics collects the backend, schema, table and storage state of all opened databases
+func (ctx *Context) Diagnostics() (*Diagnostics, error) {
+	d := &Diagnostics{ReadOnly: ctx.ReadOnly, Databases: []*DatabaseDiagnostics{}}
+	// first database name per postgres connection
+	connections := map[string]string{}
+	for _, cdb := range ctx.databases {
+		shared_with := ""
+		if cdb.dbtype == types.DATABASE_POSTGRES {
+			shared_with = connections[cdb.pg_connection]
+			if shared_with == "" {
+				connections[cdb.pg_connection] = cdb.name
+			}
+		}
+		dd, err := cdb.diagnostics(shared_with)
+		if err != nil {
+			return nil, fmt.Errorf("%s database: %v", cdb.name, err)
+		}
+		d.Databases = append(d.Databases, dd)
+	}
+	return d, nil
+}
+
+func (cdb *contextDatabase) diagnostics(shared_with string) (*DatabaseDiagnostics, error) {
+	var err error
+	dd := &DatabaseDiagnostics{Name: cdb.name, Backend: cdb.dbtype, SharedWith: shared_with}
+
+	dd.Schema, err = cdb.migrations.Status(cdb.db, cdb.dbtype)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cdb.dbtype {
+	case types.DATABASE_POSTGRES:
+		if shared_with != "" {
+			// tables already listed in the first database
+			dd.Tables = []*TableDiagnostics{}
+			break
+		}
+		dd.Tables, err = postgresTables(cdb.db)
+		if err != nil {
+			return nil, err
+		}
+	case types.DATABASE_SQLITE:
+		dd.Tables, err = sqliteTables(cdb.db)
+		if err != nil {
+			return nil, err
+		}
+		dd.Sqlite = &SqliteDiagnostics{}
+		err = cdb.db.QueryRow("pragma journal_mode").Scan(&dd.Sqlite.JournalMode)
+		if err == nil {
+			err = cdb.db.QueryRow("pragma page_size").Scan(&dd.Sqlite.PageSize)
+		}
+		if err == nil {
+			err = cdb.db.QueryRow("pragma page_count").Scan(&dd.Sqlite.PageCount)
+		}
+		if err == nil {
+			err = cdb.db.QueryRow("pragma freelist_count").Scan(&dd.Sqlite.FreelistCount)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cdb.name == DATABASE_MAP {
+			dd.Layout, err = block.GetSqliteLayout(cdb.db)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dd, nil
+}
+
+// returns the names of all tables of the given query, ordered by name
+func tableNames(db *sql.DB, q string) ([]string, error) {
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func countRows(db *sql.DB, table string) (int64, error) {
+	var count int64
+	err := db.QueryRow(fmt.Sprintf(`select count(*) from "%s"`, table)).Scan(&count)
+	return count, err
+}
+
+func sqliteTables(db *sql.DB) ([]*TableDiagnostics, error) {
+	names, err := tableNames(db, "select name from sqlite_master where type = 'table' and name not like 'sqlite_%' order by name")
+	if err != nil {
+		return nil, err
+	}
+	tables := []*TableDiagnostics{}
+	for _, name := range names {
+		td := &TableDiagnostics{Name: name}
+		td.Rows, err = countRows(db, name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, td)
+	}
+	return tables, nil
+}
+
+func postgresTables(db *sql.DB) ([]*TableDiagnostics, error) {
+	names, err := tableNames(db, "select relname from pg_stat_user_tables where schemaname = current_schema() order by relname")
+	if err != nil {
+		return nil, err
+	}
+	tables := []*TableDiagnostics{}
+	for _, name := range names {
+		td := &TableDiagnostics{Name: name}
+		td.Rows, err = countRows(db, name)
+		if err != nil {
+			return nil, err
+		}
+		err = db.QueryRow(`
+			select pg_total_relation_size(relid), n_dead_tup
+			from pg_stat_user_tables
+			where schemaname = current_schema() and relname = $1`, name).Scan(&td.Size, &td.DeadRows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, td)
+	}
+	return tables, nil
+}
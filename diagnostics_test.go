@@ -0,0 +1,54 @@
+package mtdb_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	wc := map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:    worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_AUTH_BACKEND:   worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_PLAYER_BACKEND: worldconfig.BACKEND_DUMMY,
+	}
+	repos, err := mtdb.NewWithConfig(tmpdir, wc)
+	assert.NoError(t, err)
+	defer repos.Close()
+
+	assert.NoError(t, repos.Blocks.Update(&block.Block{PosX: 1, PosY: 2, PosZ: 3, Data: []byte{0x00}}))
+
+	d, err := repos.Diagnostics()
+	assert.NoError(t, err)
+	assert.False(t, d.ReadOnly)
+	// map, auth and mod_storage
+	assert.Equal(t, 3, len(d.Databases))
+
+	m := d.Databases[0]
+	assert.Equal(t, mtdb.DATABASE_MAP, m.Name)
+	assert.Equal(t, types.DATABASE_SQLITE, m.Backend)
+	assert.Equal(t, block.SQLITE_LAYOUT_POS, m.Layout)
+	assert.NotNil(t, m.Sqlite)
+	assert.Equal(t, "wal", m.Sqlite.JournalMode)
+	assert.True(t, m.Sqlite.PageSize > 0)
+	assert.Equal(t, 0, len(m.Schema.Pending))
+
+	tables := map[string]int64{}
+	for _, td := range m.Tables {
+		tables[td.Name] = td.Rows
+	}
+	assert.Equal(t, int64(1), tables["blocks"])
+	assert.Contains(t, tables, "mtdb_schema_version")
+
+	assert.Equal(t, mtdb.DATABASE_AUTH, d.Databases[1].Name)
+	assert.Equal(t, block.SqliteLayout(""), d.Databases[1].Layout)
+	assert.Equal(t, mtdb.DATABASE_MOD_STORAGE, d.Databases[2].Name)
+}
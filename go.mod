@@ -3,6 +3,7 @@ module github.com/minetest-go/mtdb
 go 1.22
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/sirupsen/logrus v1.9.3
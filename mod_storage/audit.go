@@ -0,0 +1,284 @@
+package mod_storage
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minetest-go/mtdb/schema"
+	"github.com/minetest-go/mtdb/types"
+)
+
+// AuditMigrations contains the schema of the opt-in "mod_storage_history" table
+var AuditMigrations = &schema.Set{
+	Name: "mod_storage_audit",
+	Migrations: map[types.DatabaseType][]*schema.Migration{
+		types.DATABASE_SQLITE: {
+			{Version: 1, Description: "history table", Up: schema.Exec(`
+			CREATE TABLE IF NOT EXISTS mod_storage_history (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				modname TEXT NOT NULL,
+				key BLOB NOT NULL,
+				old_value BLOB,
+				new_value BLOB,
+				operation TEXT NOT NULL,
+				actor TEXT NOT NULL,
+				timestamp INTEGER NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS mod_storage_history_key ON mod_storage_history (modname, key);
+			`)},
+		},
+		types.DATABASE_POSTGRES: {
+			{Version: 1, Description: "history table", Up: schema.Exec(`
+			CREATE TABLE IF NOT EXISTS mod_storage_history (
+				id SERIAL PRIMARY KEY,
+				modname TEXT NOT NULL,
+				key BYTEA NOT NULL,
+				old_value BYTEA,
+				new_value BYTEA,
+				operation TEXT NOT NULL,
+				actor TEXT NOT NULL,
+				timestamp BIGINT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS mod_storage_history_key ON mod_storage_history (modname, key);
+			`)},
+		},
+	},
+}
+
+// MigrateModStorageAuditDB creates the history table used by the AuditRepository
+func MigrateModStorageAuditDB(db *sql.DB, dbtype types.DatabaseType) error {
+	return AuditMigrations.Migrate(db, dbtype)
+}
+
+const (
+	AUDIT_CREATE = "create"
+	AUDIT_UPDATE = "update"
+	AUDIT_SET    = "set"
+	AUDIT_DELETE = "delete"
+	AUDIT_REVERT = "revert"
+)
+
+// HistoryEntry is a single recorded change, nil values stand for a missing entry
+type HistoryEntry struct {
+	ID        int64  `json:"id"`
+	ModName   string `json:"modname"`
+	Key       []byte `json:"key"`
+	OldValue  []byte `json:"old_value"`
+	NewValue  []byte `json:"new_value"`
+	Operation string `json:"operation"`
+	Actor     string `json:"actor"`
+	// unix timestamp in seconds
+	Timestamp int64 `json:"timestamp"`
+}
+
+// AuditRepository records the old and new value of every write made through it in the
+// "mod_storage_history" table, see MigrateModStorageAuditDB.
+// The write and its history entry are stored in a single transaction.
+// Writes made by the engine or other tools are not recorded
+type AuditRepository struct {
+	ModStorageRepository
+	db     dbtx
+	dbtype types.DatabaseType
+	actor  string
+}
+
+// NewAuditRepository creates an audited mod storage repository, the writes are recorded with the given actor
+func NewAuditRepository(db *sql.DB, dbtype types.DatabaseType, actor string) *AuditRepository {
+	repo := NewModStorageRepository(db, dbtype)
+	if repo == nil {
+		return nil
+	}
+	return &AuditRepository{ModStorageRepository: repo, db: db, dbtype: dbtype, actor: actor}
+}
+
+// WithActor returns a copy of the repository that records the writes with the given actor
+func (repo *AuditRepository) WithActor(actor string) *AuditRepository {
+	return &AuditRepository{ModStorageRepository: repo.ModStorageRepository, db: repo.db, dbtype: repo.dbtype, actor: actor}
+}
+
+// calls fn with a copy of the repository bound to a single transaction
+func (repo *AuditRepository) audited(fn func(*AuditRepository) error) error {
+	return runTransaction(repo.db, repo.dbtype, func(tx dbtx) error {
+		return fn(&AuditRepository{
+			ModStorageRepository: newModStorageRepository(tx, repo.dbtype),
+			db:                   tx,
+			dbtype:               repo.dbtype,
+			actor:                repo.actor,
+		})
+	})
+}
+
+func (repo *AuditRepository) transaction(fn func(ModStorageRepository) error) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		return fn(tx)
+	})
+}
+
+func (repo *AuditRepository) record(modname string, key, oldValue, newValue []byte, operation string) error {
+	_, err := repo.db.Exec(`
+		insert into mod_storage_history(modname,key,old_value,new_value,operation,actor,timestamp)
+		values($1,$2,$3,$4,$5,$6,$7)`,
+		modname, key, oldValue, newValue, operation, repo.actor, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("history error: %v", err)
+	}
+	return nil
+}
+
+// returns the current value or nil
+func (repo *AuditRepository) currentValue(modname string, key []byte) ([]byte, error) {
+	entry, err := repo.Get(modname, key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.Value, nil
+}
+
+func (repo *AuditRepository) Create(entry *ModStorageEntry) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		err := tx.ModStorageRepository.Create(entry)
+		if err != nil {
+			return err
+		}
+		return tx.record(entry.ModName, entry.Key, nil, entry.Value, AUDIT_CREATE)
+	})
+}
+
+func (repo *AuditRepository) Update(entry *ModStorageEntry) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		old, err := tx.currentValue(entry.ModName, entry.Key)
+		if err != nil {
+			return err
+		}
+		err = tx.ModStorageRepository.Update(entry)
+		if err != nil || old == nil {
+			// nothing updated
+			return err
+		}
+		return tx.record(entry.ModName, entry.Key, old, entry.Value, AUDIT_UPDATE)
+	})
+}
+
+func (repo *AuditRepository) Set(entry *ModStorageEntry) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		return tx.set(entry, AUDIT_SET)
+	})
+}
+
+func (repo *AuditRepository) set(entry *ModStorageEntry, operation string) error {
+	old, err := repo.currentValue(entry.ModName, entry.Key)
+	if err != nil {
+		return err
+	}
+	err = repo.ModStorageRepository.Set(entry)
+	if err != nil {
+		return err
+	}
+	return repo.record(entry.ModName, entry.Key, old, entry.Value, operation)
+}
+
+func (repo *AuditRepository) SetIf(modname string, key, expected, value []byte) (bool, error) {
+	ok := false
+	err := repo.audited(func(tx *AuditRepository) error {
+		var err error
+		ok, err = tx.ModStorageRepository.SetIf(modname, key, expected, value)
+		if err != nil || !ok {
+			return err
+		}
+		return tx.record(modname, key, expected, value, AUDIT_SET)
+	})
+	return ok && err == nil, err
+}
+
+func (repo *AuditRepository) Delete(modname string, key []byte) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		return tx.delete(modname, key, AUDIT_DELETE)
+	})
+}
+
+func (repo *AuditRepository) delete(modname string, key []byte, operation string) error {
+	old, err := repo.currentValue(modname, key)
+	if err != nil {
+		return err
+	}
+	err = repo.ModStorageRepository.Delete(modname, key)
+	if err != nil || old == nil {
+		return err
+	}
+	return repo.record(modname, key, old, nil, operation)
+}
+
+func (repo *AuditRepository) DeleteMod(modname string) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		entries, err := tx.GetAll(modname)
+		if err != nil {
+			return err
+		}
+		err = tx.ModStorageRepository.DeleteMod(modname)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err = tx.record(modname, entry.Key, entry.Value, nil, AUDIT_DELETE)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// History returns the recorded changes of the mod (or only of a single key if not nil), oldest first
+func (repo *AuditRepository) History(modname string, key []byte) ([]*HistoryEntry, error) {
+	q := "select id,modname,key,old_value,new_value,operation,actor,timestamp from mod_storage_history where modname = $1"
+	params := []any{modname}
+	if key != nil {
+		q += " and key = $2"
+		params = append(params, key)
+	}
+	rows, err := repo.db.Query(q+" order by id", params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*HistoryEntry{}
+	for rows.Next() {
+		e := &HistoryEntry{}
+		err = rows.Scan(&e.ID, &e.ModName, &e.Key, &e.OldValue, &e.NewValue, &e.Operation, &e.Actor, &e.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// Revert restores the value of the entry from before the given change, the revert itself is recorded as well
+func (repo *AuditRepository) Revert(id int64) error {
+	return repo.audited(func(tx *AuditRepository) error {
+		e := &HistoryEntry{}
+		err := tx.db.QueryRow("select modname,key,old_value from mod_storage_history where id = $1", id).Scan(&e.ModName, &e.Key, &e.OldValue)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("history entry %d not found", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if e.OldValue == nil {
+			return tx.delete(e.ModName, e.Key, AUDIT_REVERT)
+		}
+		current, err := tx.currentValue(e.ModName, e.Key)
+		if err != nil {
+			return err
+		}
+		if current != nil && bytes.Equal(current, e.OldValue) {
+			// already at that value
+			return nil
+		}
+		return tx.set(&ModStorageEntry{ModName: e.ModName, Key: e.Key, Value: e.OldValue}, AUDIT_REVERT)
+	})
+}
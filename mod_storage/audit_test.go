@@ -0,0 +1,124 @@
+package mod_storage_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuditRepository(t *testing.T, db *sql.DB, dbtype types.DatabaseType) {
+	assert.NoError(t, mod_storage.MigrateModStorageAuditDB(db, dbtype))
+	assert.NoError(t, mod_storage.MigrateModStorageAuditDB(db, dbtype))
+	repo := mod_storage.NewAuditRepository(db, dbtype, "admin")
+	assert.NotNil(t, repo)
+	assert.NoError(t, repo.DeleteMod("auditmod"))
+
+	key := []byte("balance")
+	get := func() []byte {
+		entry, err := repo.Get("auditmod", key)
+		assert.NoError(t, err)
+		if entry == nil {
+			return nil
+		}
+		return entry.Value
+	}
+
+	assert.NoError(t, repo.Create(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: key, Value: []byte("100")}))
+	assert.NoError(t, repo.Update(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: key, Value: []byte("200")}))
+	assert.NoError(t, repo.WithActor("mod:economy").Set(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: key, Value: []byte("-5")}))
+	// not swapped, not recorded
+	ok, err := repo.SetIf("auditmod", key, []byte("200"), []byte("300"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	// missing entry, not recorded
+	assert.NoError(t, repo.Update(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: []byte("other"), Value: []byte("x")}))
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: []byte("other"), Value: []byte("x")}))
+
+	history, err := repo.History("auditmod", key)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, mod_storage.AUDIT_CREATE, history[0].Operation)
+	assert.Nil(t, history[0].OldValue)
+	assert.Equal(t, []byte("100"), history[0].NewValue)
+	assert.Equal(t, "admin", history[0].Actor)
+	assert.True(t, history[0].Timestamp > 0)
+	assert.Equal(t, []byte("100"), history[1].OldValue)
+	assert.Equal(t, []byte("200"), history[1].NewValue)
+	assert.Equal(t, "mod:economy", history[2].Actor)
+
+	history, err = repo.History("auditmod", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(history))
+
+	// revert the corrupting write
+	history, err = repo.History("auditmod", key)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Revert(history[2].ID))
+	assert.Equal(t, []byte("200"), get())
+	// idempotent
+	assert.NoError(t, repo.Revert(history[2].ID))
+
+	// revert the creation
+	assert.NoError(t, repo.Revert(history[0].ID))
+	assert.Nil(t, get())
+
+	history, err = repo.History("auditmod", key)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(history))
+	assert.Equal(t, mod_storage.AUDIT_REVERT, history[3].Operation)
+	assert.Equal(t, mod_storage.AUDIT_REVERT, history[4].Operation)
+	assert.Nil(t, history[4].NewValue)
+
+	assert.Error(t, repo.Revert(-1))
+
+	// mod removal records every entry
+	assert.NoError(t, repo.DeleteMod("auditmod"))
+	history, err = repo.History("auditmod", []byte("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, mod_storage.AUDIT_DELETE, history[1].Operation)
+	assert.Equal(t, []byte("x"), history[1].OldValue)
+
+	// imports are recorded
+	doc := &mod_storage.ExportDocument{Version: mod_storage.ExportVersion, Mods: map[string][]*mod_storage.ExportEntry{
+		"auditmod": {{Key: key, Value: mod_storage.JSONBytes("1")}},
+	}}
+	_, err = mod_storage.Import(repo, doc, mod_storage.IMPORT_REPLACE)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), get())
+	history, err = repo.History("auditmod", key)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(history))
+
+	// writes are rolled back if the history can't be recorded
+	_, err = db.Exec("alter table mod_storage_history rename to mod_storage_history_old")
+	assert.NoError(t, err)
+	assert.Error(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "auditmod", Key: key, Value: []byte("2")}))
+	assert.Error(t, repo.DeleteMod("auditmod"))
+	ok, err = repo.SetIf("auditmod", key, []byte("1"), []byte("2"))
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("1"), get())
+	_, err = db.Exec("alter table mod_storage_history_old rename to mod_storage_history")
+	assert.NoError(t, err)
+}
+
+func TestAuditRepositorySqlite(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "mod_storage.sqlite")
+	assert.NoError(t, err)
+	copyFileContents("testdata/mod_storage.sqlite", dbfile.Name())
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	testAuditRepository(t, db, types.DATABASE_SQLITE)
+}
+
+func TestAuditRepositoryPostgres(t *testing.T) {
+	db := getPostgresDB(t)
+	testAuditRepository(t, db, types.DATABASE_POSTGRES)
+}
@@ -0,0 +1,149 @@
+package mod_storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// ExportVersion is the current version of the export document format
+const ExportVersion = 1
+
+// JSONBytes is a binary-safe json representation of a byte slice:
+// valid utf-8 is encoded as plain string, everything else as {"base64": "..."}
+type JSONBytes []byte
+
+type base64Bytes struct {
+	Base64 string `json:"base64"`
+}
+
+func (b JSONBytes) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(&base64Bytes{Base64: base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *JSONBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*b = JSONBytes(s)
+		return nil
+	}
+	v := &base64Bytes{}
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("expected string or base64 object: %v", err)
+	}
+	*b, err = base64.StdEncoding.DecodeString(v.Base64)
+	return err
+}
+
+// ExportEntry is a single exported key-value pair
+type ExportEntry struct {
+	Key   JSONBytes `json:"key"`
+	Value JSONBytes `json:"value"`
+}
+
+// ExportDocument contains the exported entries per mod
+type ExportDocument struct {
+	Version int                       `json:"version"`
+	Mods    map[string][]*ExportEntry `json:"mods"`
+}
+
+// Export returns the entries of the given mods (or all mods if none are given), ordered by key
+func Export(repo ModStorageRepository, modnames ...string) (*ExportDocument, error) {
+	if len(modnames) == 0 {
+		var err error
+		modnames, err = repo.ListMods()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &ExportDocument{Version: ExportVersion, Mods: map[string][]*ExportEntry{}}
+	for _, modname := range modnames {
+		entries, err := repo.GetAll(modname)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]*ExportEntry, len(entries))
+		for i, entry := range entries {
+			list[i] = &ExportEntry{Key: entry.Key, Value: entry.Value}
+		}
+		doc.Mods[modname] = list
+	}
+	return doc, nil
+}
+
+type ImportMode string
+
+const (
+	// sets the imported entries, other entries of the mod are kept
+	IMPORT_MERGE ImportMode = "merge"
+	// removes all entries of the imported mods before setting the imported ones
+	IMPORT_REPLACE ImportMode = "replace"
+)
+
+// ImportResult contains the counters of an import
+type ImportResult struct {
+	// number of imported mods
+	Mods int `json:"mods"`
+	// number of set entries
+	Entries int64 `json:"entries"`
+	// number of removed entries (replace mode only)
+	Deleted int64 `json:"deleted"`
+}
+
+// Import writes the entries of the export document into the repository.
+// Each mod is imported in a single transaction, if the import fails the mods imported before are kept
+func Import(repo ModStorageRepository, doc *ExportDocument, mode ImportMode) (*ImportResult, error) {
+	if mode != IMPORT_MERGE && mode != IMPORT_REPLACE {
+		return nil, fmt.Errorf("unknown import mode: '%s'", mode)
+	}
+	if doc.Version != ExportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", doc.Version)
+	}
+
+	modnames := make([]string, 0, len(doc.Mods))
+	for modname := range doc.Mods {
+		modnames = append(modnames, modname)
+	}
+	sort.Strings(modnames)
+
+	result := &ImportResult{}
+	for _, modname := range modnames {
+		var entries, deleted int64
+		err := Transaction(repo, func(tx ModStorageRepository) error {
+			if mode == IMPORT_REPLACE {
+				count, err := tx.CountMod(modname)
+				if err != nil {
+					return err
+				}
+				err = tx.DeleteMod(modname)
+				if err != nil {
+					return err
+				}
+				deleted = count
+			}
+
+			for _, entry := range doc.Mods[modname] {
+				err := tx.Set(&ModStorageEntry{ModName: modname, Key: entry.Key, Value: entry.Value})
+				if err != nil {
+					return err
+				}
+				entries++
+			}
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("import of '%s' failed: %v", modname, err)
+		}
+		result.Entries += entries
+		result.Deleted += deleted
+		result.Mods++
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package mod_storage_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONBytes(t *testing.T) {
+	data, err := json.Marshal(mod_storage.JSONBytes("text"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"text"`, string(data))
+
+	data, err = json.Marshal(mod_storage.JSONBytes{0xff, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"base64":"/wA="}`, string(data))
+
+	var b mod_storage.JSONBytes
+	assert.NoError(t, json.Unmarshal([]byte(`"text"`), &b))
+	assert.Equal(t, mod_storage.JSONBytes("text"), b)
+	assert.NoError(t, json.Unmarshal([]byte(`{"base64":"/wA="}`), &b))
+	assert.Equal(t, mod_storage.JSONBytes{0xff, 0x00}, b)
+	assert.Error(t, json.Unmarshal([]byte(`123`), &b))
+}
+
+func TestExportImport(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "mod_storage.sqlite")
+	assert.NoError(t, err)
+	copyFileContents("testdata/mod_storage.sqlite", dbfile.Name())
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	repo := mod_storage.NewModStorageRepository(db, types.DATABASE_SQLITE)
+
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "economy", Key: []byte("balance"), Value: []byte{0x01, 0xff}}))
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "economy", Key: []byte("bank"), Value: []byte("1000")}))
+
+	// single mod
+	doc, err := mod_storage.Export(repo, "economy")
+	assert.NoError(t, err)
+	data, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":1,"mods":{"economy":[{"key":"balance","value":{"base64":"Af8="}},{"key":"bank","value":"1000"}]}}`, string(data))
+
+	// all mods
+	doc, err = mod_storage.Export(repo)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(doc.Mods))
+	assert.Equal(t, 2, len(doc.Mods["i3"]))
+
+	// change and restore with merge
+	backup := &mod_storage.ExportDocument{}
+	assert.NoError(t, json.Unmarshal(data, backup))
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "economy", Key: []byte("bank"), Value: []byte("0")}))
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "economy", Key: []byte("new"), Value: []byte("x")}))
+
+	result, err := mod_storage.Import(repo, backup, mod_storage.IMPORT_MERGE)
+	assert.NoError(t, err)
+	assert.Equal(t, &mod_storage.ImportResult{Mods: 1, Entries: 2}, result)
+
+	entry, err := repo.Get("economy", []byte("bank"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1000"), entry.Value)
+	entry, err = repo.Get("economy", []byte("balance"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0xff}, entry.Value)
+	count, err := repo.CountMod("economy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// replace removes the new key
+	result, err = mod_storage.Import(repo, backup, mod_storage.IMPORT_REPLACE)
+	assert.NoError(t, err)
+	assert.Equal(t, &mod_storage.ImportResult{Mods: 1, Entries: 2, Deleted: 3}, result)
+	count, err = repo.CountMod("economy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// other mods untouched
+	count, err = repo.CountMod("i3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// failed replace is rolled back
+	broken := &mod_storage.ExportDocument{Version: mod_storage.ExportVersion, Mods: map[string][]*mod_storage.ExportEntry{
+		"economy": {{Key: mod_storage.JSONBytes("bank"), Value: mod_storage.JSONBytes("1")}, {Key: mod_storage.JSONBytes("broken")}},
+	}}
+	result, err = mod_storage.Import(repo, broken, mod_storage.IMPORT_REPLACE)
+	assert.Error(t, err)
+	assert.Equal(t, &mod_storage.ImportResult{}, result)
+	entry, err = repo.Get("economy", []byte("bank"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1000"), entry.Value)
+	count, err = repo.CountMod("economy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// invalid
+	_, err = mod_storage.Import(repo, backup, "overwrite")
+	assert.Error(t, err)
+	_, err = mod_storage.Import(repo, &mod_storage.ExportDocument{Version: 99}, mod_storage.IMPORT_MERGE)
+	assert.Error(t, err)
+}
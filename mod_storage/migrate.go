@@ -3,28 +3,36 @@ package mod_storage
 import (
 	"database/sql"
 
+	"github.com/minetest-go/mtdb/schema"
 	"github.com/minetest-go/mtdb/types"
 )
 
-func MigrateModStorageDB(db *sql.DB, dbtype types.DatabaseType) error {
-	var err error
-	switch dbtype {
-	case types.DATABASE_SQLITE:
-		_, err = db.Exec(`
+// Migrations contains the numbered schema migrations of the mod_storage database,
+// the initial schema is compatible with the tables created by the engine
+var Migrations = &schema.Set{
+	Name: "mod_storage",
+	Migrations: map[types.DatabaseType][]*schema.Migration{
+		types.DATABASE_SQLITE: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`
 		CREATE TABLE IF NOT EXISTS entries (
 			modname TEXT NOT NULL,
 			key BLOB NOT NULL,
 			value BLOB NOT NULL,
 			PRIMARY KEY (modname, key)
-		)`)
-	case types.DATABASE_POSTGRES:
-		_, err = db.Exec(`
+		)`)},
+		},
+		types.DATABASE_POSTGRES: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`
 		CREATE TABLE IF NOT EXISTS mod_storage (
 			modname TEXT NOT NULL,
 			key BYTEA NOT NULL,
 			value BYTEA NOT NULL,
 			PRIMARY KEY (modname, key)
-		)`)
-	}
-	return err
+		)`)},
+		},
+	},
+}
+
+func MigrateModStorageDB(db *sql.DB, dbtype types.DatabaseType) error {
+	return Migrations.Migrate(db, dbtype)
 }
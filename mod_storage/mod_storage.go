@@ -19,9 +19,120 @@ type ModStorageRepository interface {
 	Update(entry *ModStorageEntry) error
 	Delete(modname string, key []byte) error
 	Count() (int64, error)
+	// Set creates or replaces the entry atomically
+	Set(entry *ModStorageEntry) error
+	// SetIf sets the value only if the current value equals the expected one (compare-and-swap),
+	// a nil expected value means the entry must not exist yet. Returns true if the value was set
+	SetIf(modname string, key, expected, value []byte) (bool, error)
+	// ListMods returns the names of all mods with stored entries
+	ListMods() ([]string, error)
+	// GetAll returns all entries of the mod ordered by key
+	GetAll(modname string) ([]*ModStorageEntry, error)
+	// Keys returns the keys of the mod with the given prefix (all keys for an empty prefix) ordered by key
+	Keys(modname string, prefix []byte) ([][]byte, error)
+	// DeleteMod removes all entries of the mod
+	DeleteMod(modname string) error
+	// CountMod returns the number of entries of the mod
+	CountMod(modname string) (int64, error)
+}
+
+func scanEntries(rows *sql.Rows) ([]*ModStorageEntry, error) {
+	defer rows.Close()
+	list := []*ModStorageEntry{}
+	for rows.Next() {
+		entry := &ModStorageEntry{}
+		err := rows.Scan(&entry.ModName, &entry.Key, &entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
+
+func scanModNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	list := []string{}
+	for rows.Next() {
+		var modname string
+		err := rows.Scan(&modname)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, modname)
+	}
+	return list, rows.Err()
+}
+
+func scanKeys(rows *sql.Rows) ([][]byte, error) {
+	defer rows.Close()
+	list := [][]byte{}
+	for rows.Next() {
+		var key []byte
+		err := rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, key)
+	}
+	return list, rows.Err()
+}
+
+// database handle of the repositories, a *sql.DB or a *sql.Tx
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// implemented by the repositories supporting transactions
+type transactional interface {
+	transaction(fn func(ModStorageRepository) error) error
+}
+
+// Transaction calls fn with a repository bound to a single database transaction,
+// the transaction is committed if fn returns nil and rolled back otherwise.
+// Repositories without transaction support are passed as-is
+func Transaction(repo ModStorageRepository, fn func(ModStorageRepository) error) error {
+	t, ok := repo.(transactional)
+	if !ok {
+		return fn(repo)
+	}
+	return t.transaction(fn)
+}
+
+// runs fn in a new transaction, an already running transaction is re-used
+func runTransaction(db dbtx, dbtype types.DatabaseType, fn func(dbtx) error) error {
+	sqldb, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := sqldb.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dbtype == types.DATABASE_SQLITE {
+		// a write as the first statement takes the sqlite write lock (waiting for the busy timeout)
+		_, err = tx.Exec("update entries set value = value where modname is null")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func NewModStorageRepository(db *sql.DB, dbtype types.DatabaseType) ModStorageRepository {
+	return newModStorageRepository(db, dbtype)
+}
+
+func newModStorageRepository(db dbtx, dbtype types.DatabaseType) ModStorageRepository {
 	switch dbtype {
 	case types.DATABASE_SQLITE:
 		return &modStorageSqliteRepository{db: db}
@@ -31,3 +142,12 @@ func NewModStorageRepository(db *sql.DB, dbtype types.DatabaseType) ModStorageRe
 		return nil
 	}
 }
+
+// returns true if exactly one row was affected
+func singleRowAffected(result sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	return count == 1, err
+}
@@ -0,0 +1,115 @@
+package mod_storage_test
+
+import (
+	"testing"
+
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func testModStorageModOperations(t *testing.T, repo mod_storage.ModStorageRepository) {
+	for _, key := range []string{"b", "a/2", "a/1", "a\x00x"} {
+		assert.NoError(t, repo.Create(&mod_storage.ModStorageEntry{ModName: "testmod", Key: []byte(key), Value: []byte("v" + key)}))
+	}
+	assert.NoError(t, repo.Create(&mod_storage.ModStorageEntry{ModName: "othermod", Key: []byte("a/1"), Value: []byte("x")}))
+
+	mods, err := repo.ListMods()
+	assert.NoError(t, err)
+	assert.Contains(t, mods, "testmod")
+	assert.Contains(t, mods, "othermod")
+
+	count, err := repo.CountMod("testmod")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	entries, err := repo.GetAll("testmod")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(entries))
+	// byte-wise order
+	assert.Equal(t, []byte("a\x00x"), entries[0].Key)
+	assert.Equal(t, []byte("a/1"), entries[1].Key)
+	assert.Equal(t, []byte("va/1"), entries[1].Value)
+	assert.Equal(t, "testmod", entries[0].ModName)
+
+	keys, err := repo.Keys("testmod", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a\x00x"), []byte("a/1"), []byte("a/2"), []byte("b")}, keys)
+
+	keys, err = repo.Keys("testmod", []byte("a/"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a/1"), []byte("a/2")}, keys)
+
+	keys, err = repo.Keys("testmod", []byte("a\x00"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a\x00x")}, keys)
+
+	keys, err = repo.Keys("testmod", []byte("c"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(keys))
+
+	// drop the mod
+	assert.NoError(t, repo.DeleteMod("testmod"))
+	count, err = repo.CountMod("testmod")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	entries, err = repo.GetAll("testmod")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	mods, err = repo.ListMods()
+	assert.NoError(t, err)
+	assert.NotContains(t, mods, "testmod")
+
+	// other mods untouched
+	count, err = repo.CountMod("othermod")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.NoError(t, repo.DeleteMod("othermod"))
+}
+
+func testModStorageSet(t *testing.T, repo mod_storage.ModStorageRepository) {
+	get := func() []byte {
+		entry, err := repo.Get("setmod", []byte("k"))
+		assert.NoError(t, err)
+		if entry == nil {
+			return nil
+		}
+		return entry.Value
+	}
+
+	// upsert
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "setmod", Key: []byte("k"), Value: []byte("1")}))
+	assert.Equal(t, []byte("1"), get())
+	assert.NoError(t, repo.Set(&mod_storage.ModStorageEntry{ModName: "setmod", Key: []byte("k"), Value: []byte("2")}))
+	assert.Equal(t, []byte("2"), get())
+
+	// compare-and-swap
+	ok, err := repo.SetIf("setmod", []byte("k"), []byte("1"), []byte("3"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("2"), get())
+
+	ok, err = repo.SetIf("setmod", []byte("k"), []byte("2"), []byte("3"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("3"), get())
+
+	// create only if missing
+	ok, err = repo.SetIf("setmod", []byte("k"), nil, []byte("4"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("3"), get())
+
+	assert.NoError(t, repo.Delete("setmod", []byte("k")))
+	ok, err = repo.SetIf("setmod", []byte("k"), nil, []byte("4"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("4"), get())
+
+	// missing entry with expected value
+	ok, err = repo.SetIf("setmod", []byte("other"), []byte("4"), []byte("5"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, repo.DeleteMod("setmod"))
+}
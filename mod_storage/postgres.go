@@ -2,10 +2,12 @@ package mod_storage
 
 import (
 	"database/sql"
+
+	"github.com/minetest-go/mtdb/types"
 )
 
 type modStoragePostgresRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func (repo *modStoragePostgresRepository) Get(modname string, key []byte) (*ModStorageEntry, error) {
@@ -28,6 +30,25 @@ func (repo *modStoragePostgresRepository) Update(entry *ModStorageEntry) error {
 	return err
 }
 
+func (repo *modStoragePostgresRepository) Set(entry *ModStorageEntry) error {
+	_, err := repo.db.Exec(`
+		insert into mod_storage(modname,key,value) values($1,$2,$3)
+		on conflict(modname,key) do update set value = excluded.value`,
+		entry.ModName, entry.Key, entry.Value)
+	return err
+}
+
+func (repo *modStoragePostgresRepository) SetIf(modname string, key, expected, value []byte) (bool, error) {
+	if expected == nil {
+		return singleRowAffected(repo.db.Exec(`
+			insert into mod_storage(modname,key,value) values($1,$2,$3)
+			on conflict(modname,key) do nothing`,
+			modname, key, value))
+	}
+	return singleRowAffected(repo.db.Exec("update mod_storage set value = $1 where modname = $2 and key = $3 and value = $4",
+		value, modname, key, expected))
+}
+
 func (repo *modStoragePostgresRepository) Delete(modname string, key []byte) error {
 	_, err := repo.db.Exec("delete from mod_storage where modname = $1 and key = $2", modname, key)
 	return err
@@ -39,3 +60,51 @@ func (repo *modStoragePostgresRepository) Count() (int64, error) {
 	err := row.Scan(&count)
 	return count, err
 }
+
+func (repo *modStoragePostgresRepository) ListMods() ([]string, error) {
+	rows, err := repo.db.Query("select distinct modname from mod_storage order by modname")
+	if err != nil {
+		return nil, err
+	}
+	return scanModNames(rows)
+}
+
+func (repo *modStoragePostgresRepository) GetAll(modname string) ([]*ModStorageEntry, error) {
+	rows, err := repo.db.Query("select modname,key,value from mod_storage where modname = $1 order by key", modname)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+func (repo *modStoragePostgresRepository) Keys(modname string, prefix []byte) ([][]byte, error) {
+	var rows *sql.Rows
+	var err error
+	if len(prefix) == 0 {
+		rows, err = repo.db.Query("select key from mod_storage where modname = $1 order by key", modname)
+	} else {
+		rows, err = repo.db.Query("select key from mod_storage where modname = $1 and substring(key from 1 for octet_length($2)) = $2 order by key", modname, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+func (repo *modStoragePostgresRepository) DeleteMod(modname string) error {
+	_, err := repo.db.Exec("delete from mod_storage where modname = $1", modname)
+	return err
+}
+
+func (repo *modStoragePostgresRepository) CountMod(modname string) (int64, error) {
+	row := repo.db.QueryRow("select count(*) from mod_storage where modname = $1", modname)
+	count := int64(0)
+	err := row.Scan(&count)
+	return count, err
+}
+
+func (repo *modStoragePostgresRepository) transaction(fn func(ModStorageRepository) error) error {
+	return runTransaction(repo.db, types.DATABASE_POSTGRES, func(tx dbtx) error {
+		return fn(&modStoragePostgresRepository{db: tx})
+	})
+}
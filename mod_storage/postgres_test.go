@@ -36,4 +36,7 @@ func TestModStoragePostgresRepo(t *testing.T) {
 	entry, err = repo.Get("mymod", []byte("mykey"))
 	assert.NoError(t, err)
 	assert.Nil(t, entry)
+
+	testModStorageModOperations(t, repo)
+	testModStorageSet(t, repo)
 }
@@ -0,0 +1,38 @@
+package mod_storage
+
+import (
+	"github.com/minetest-go/mtdb/types"
+)
+
+type modStorageReadOnlyRepository struct {
+	ModStorageRepository
+}
+
+// NewReadOnlyModStorageRepository wraps the given repository, all write operations return types.ErrReadOnly
+func NewReadOnlyModStorageRepository(repo ModStorageRepository) ModStorageRepository {
+	return &modStorageReadOnlyRepository{ModStorageRepository: repo}
+}
+
+func (repo *modStorageReadOnlyRepository) Create(entry *ModStorageEntry) error {
+	return types.ErrReadOnly
+}
+
+func (repo *modStorageReadOnlyRepository) Update(entry *ModStorageEntry) error {
+	return types.ErrReadOnly
+}
+
+func (repo *modStorageReadOnlyRepository) Set(entry *ModStorageEntry) error {
+	return types.ErrReadOnly
+}
+
+func (repo *modStorageReadOnlyRepository) SetIf(modname string, key, expected, value []byte) (bool, error) {
+	return false, types.ErrReadOnly
+}
+
+func (repo *modStorageReadOnlyRepository) Delete(modname string, key []byte) error {
+	return types.ErrReadOnly
+}
+
+func (repo *modStorageReadOnlyRepository) DeleteMod(modname string) error {
+	return types.ErrReadOnly
+}
@@ -0,0 +1,38 @@
+package mod_storage_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModStorageReadOnly(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "mod_storage.sqlite")
+	assert.NoError(t, err)
+	copyFileContents("testdata/mod_storage.sqlite", dbfile.Name())
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	repo := mod_storage.NewReadOnlyModStorageRepository(mod_storage.NewModStorageRepository(db, types.DATABASE_SQLITE))
+
+	// reads
+	entry, err := repo.Get("i3", []byte("data"))
+	assert.NoError(t, err)
+	assert.NotNil(t, entry)
+	keys, err := repo.Keys("i3", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(keys))
+
+	// writes
+	entry = &mod_storage.ModStorageEntry{ModName: "i3", Key: []byte("data"), Value: []byte("x")}
+	assert.ErrorIs(t, repo.Create(entry), types.ErrReadOnly)
+	assert.ErrorIs(t, repo.Update(entry), types.ErrReadOnly)
+	assert.ErrorIs(t, repo.Set(entry), types.ErrReadOnly)
+	_, err = repo.SetIf("i3", []byte("data"), nil, []byte("x"))
+	assert.ErrorIs(t, err, types.ErrReadOnly)
+	assert.ErrorIs(t, repo.Delete("i3", []byte("data")), types.ErrReadOnly)
+	assert.ErrorIs(t, repo.DeleteMod("i3"), types.ErrReadOnly)
+}
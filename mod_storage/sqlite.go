@@ -2,10 +2,12 @@ package mod_storage
 
 import (
 	"database/sql"
+
+	"github.com/minetest-go/mtdb/types"
 )
 
 type modStorageSqliteRepository struct {
-	db *sql.DB
+	db dbtx
 }
 
 func (repo *modStorageSqliteRepository) Get(modname string, key []byte) (*ModStorageEntry, error) {
@@ -28,6 +30,25 @@ func (repo *modStorageSqliteRepository) Update(entry *ModStorageEntry) error {
 	return err
 }
 
+func (repo *modStorageSqliteRepository) Set(entry *ModStorageEntry) error {
+	_, err := repo.db.Exec(`
+		insert into entries(modname,key,value) values($1,$2,$3)
+		on conflict(modname,key) do update set value = excluded.value`,
+		entry.ModName, entry.Key, entry.Value)
+	return err
+}
+
+func (repo *modStorageSqliteRepository) SetIf(modname string, key, expected, value []byte) (bool, error) {
+	if expected == nil {
+		return singleRowAffected(repo.db.Exec(`
+			insert into entries(modname,key,value) values($1,$2,$3)
+			on conflict(modname,key) do nothing`,
+			modname, key, value))
+	}
+	return singleRowAffected(repo.db.Exec("update entries set value = $1 where modname = $2 and key = $3 and value = $4",
+		value, modname, key, expected))
+}
+
 func (repo *modStorageSqliteRepository) Delete(modname string, key []byte) error {
 	_, err := repo.db.Exec("delete from entries where modname = $1 and key = $2", modname, key)
 	return err
@@ -39,3 +60,51 @@ func (repo *modStorageSqliteRepository) Count() (int64, error) {
 	err := row.Scan(&count)
 	return count, err
 }
+
+func (repo *modStorageSqliteRepository) ListMods() ([]string, error) {
+	rows, err := repo.db.Query("select distinct modname from entries order by modname")
+	if err != nil {
+		return nil, err
+	}
+	return scanModNames(rows)
+}
+
+func (repo *modStorageSqliteRepository) GetAll(modname string) ([]*ModStorageEntry, error) {
+	rows, err := repo.db.Query("select modname,key,value from entries where modname = $1 order by key", modname)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+func (repo *modStorageSqliteRepository) Keys(modname string, prefix []byte) ([][]byte, error) {
+	var rows *sql.Rows
+	var err error
+	if len(prefix) == 0 {
+		rows, err = repo.db.Query("select key from entries where modname = $1 order by key", modname)
+	} else {
+		rows, err = repo.db.Query("select key from entries where modname = $1 and substr(key, 1, length($2)) = $2 order by key", modname, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+func (repo *modStorageSqliteRepository) DeleteMod(modname string) error {
+	_, err := repo.db.Exec("delete from entries where modname = $1", modname)
+	return err
+}
+
+func (repo *modStorageSqliteRepository) CountMod(modname string) (int64, error) {
+	row := repo.db.QueryRow("select count(*) from entries where modname = $1", modname)
+	count := int64(0)
+	err := row.Scan(&count)
+	return count, err
+}
+
+func (repo *modStorageSqliteRepository) transaction(fn func(ModStorageRepository) error) error {
+	return runTransaction(repo.db, types.DATABASE_SQLITE, func(tx dbtx) error {
+		return fn(&modStorageSqliteRepository{db: tx})
+	})
+}
@@ -58,4 +58,11 @@ func TestModStorageSQliteRepo(t *testing.T) {
 	entry, err = repo.Get("mymod", []byte("mykey"))
 	assert.NoError(t, err)
 	assert.Nil(t, entry)
+
+	mods, err := repo.ListMods()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"i3"}, mods)
+
+	testModStorageModOperations(t, repo)
+	testModStorageSet(t, repo)
 }
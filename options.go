@@ -0,0 +1,187 @@
+package mtdb
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/minetest-go/mtdb/worldconfig"
+)
+
+const (
+	DEFAULT_ENV_PREFIX          = "MTDB_"
+	DEFAULT_SQLITE_BUSY_TIMEOUT = 15000
+	DEFAULT_SQLITE_SYNCHRONOUS  = "NORMAL"
+	DEFAULT_SQLITE_CACHE_MODE   = "shared"
+)
+
+// ConnectionOptions configures the connection and pooling of a single database,
+// zero values keep the defaults
+type ConnectionOptions struct {
+	// sqlite busy timeout in milliseconds
+	BusyTimeout int `json:"busy_timeout"`
+	// sqlite synchronous mode: OFF, NORMAL, FULL or EXTRA
+	Synchronous string `json:"synchronous"`
+	// sqlite cache mode: shared or private
+	CacheMode string `json:"cache_mode"`
+	// maximum number of open connections
+	MaxOpenConns int `json:"max_open_conns"`
+	// maximum number of idle connections
+	MaxIdleConns int `json:"max_idle_conns"`
+	// maximum lifetime of a connection
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	// maximum idle time of a connection
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+}
+
+// Options contains the context options and the connection options per database, nil entries keep the defaults.
+//
+// The settings are resolved in the following order, later sources take precedence:
+//  1. minetest.conf (if MinetestConf is set)
+//  2. world.mt
+//  3. environment variables (if EnvPrefix is set)
+//  4. Overrides
+//
+// Multiple options passed to the constructors are merged in order, set fields of later options take precedence
+type Options struct {
+	// optional path to a minetest.conf file
+	MinetestConf string `json:"minetest_conf"`
+	// optional environment variable prefix, "MTDB_PGSQL_CONNECTION" overrides "pgsql_connection" with the "MTDB_" prefix
+	EnvPrefix string `json:"env_prefix"`
+	// optional setting overrides
+	Overrides map[string]string `json:"overrides"`
+	// optional actor name, enables the mod storage audit mode (see mod_storage.AuditRepository)
+	ModStorageAuditActor string `json:"mod_storage_audit_actor"`
+	// installs the change-tracking triggers of the map database for BlockRepository.Watch
+	// (see block.MigrateBlockWatchDB), ignored in read-only mode
+	WatchBlocks bool `json:"watch_blocks"`
+
+	Map        *ConnectionOptions `json:"map"`
+	Auth       *ConnectionOptions `json:"auth"`
+	Player     *ConnectionOptions `json:"player"`
+	ModStorage *ConnectionOptions `json:"mod_storage"`
+}
+
+// merges the given options in order, set fields of later options take precedence
+// and the override settings are combined
+func getOptions(opts_list []*Options) *Options {
+	merged := &Options{}
+	for _, opts := range opts_list {
+		if opts == nil {
+			continue
+		}
+		if opts.MinetestConf != "" {
+			merged.MinetestConf = opts.MinetestConf
+		}
+		if opts.EnvPrefix != "" {
+			merged.EnvPrefix = opts.EnvPrefix
+		}
+		if len(opts.Overrides) > 0 {
+			if merged.Overrides == nil {
+				merged.Overrides = map[string]string{}
+			}
+			for k, v := range opts.Overrides {
+				merged.Overrides[k] = v
+			}
+		}
+		if opts.ModStorageAuditActor != "" {
+			merged.ModStorageAuditActor = opts.ModStorageAuditActor
+		}
+		merged.WatchBlocks = merged.WatchBlocks || opts.WatchBlocks
+		if opts.Map != nil {
+			merged.Map = opts.Map
+		}
+		if opts.Auth != nil {
+			merged.Auth = opts.Auth
+		}
+		if opts.Player != nil {
+			merged.Player = opts.Player
+		}
+		if opts.ModStorage != nil {
+			merged.ModStorage = opts.ModStorage
+		}
+	}
+	return merged
+}
+
+// returns the migration function of the map database
+func (o *Options) blockMigrateFn() func(*sql.DB, types.DatabaseType) error {
+	if !o.WatchBlocks {
+		return block.MigrateBlockDB
+	}
+	return func(db *sql.DB, dbtype types.DatabaseType) error {
+		err := block.MigrateBlockDB(db, dbtype)
+		if err != nil {
+			return err
+		}
+		return block.MigrateBlockWatchDB(db, dbtype)
+	}
+}
+
+// applies the minetest.conf, environment and override settings to the world.mt settings
+func (o *Options) resolveConfig(wc map[string]string) (map[string]string, error) {
+	layers := []map[string]string{}
+	if o.MinetestConf != "" {
+		settings, err := worldconfig.LoadMinetestConf(o.MinetestConf)
+		if err != nil {
+			return nil, fmt.Errorf("minetest.conf error: %v", err)
+		}
+		layers = append(layers, settings.ToMap())
+	}
+	layers = append(layers, wc)
+	if o.EnvPrefix != "" {
+		layers = append(layers, worldconfig.EnvSettings(o.EnvPrefix))
+	}
+	layers = append(layers, o.Overrides)
+	return worldconfig.Merge(layers...), nil
+}
+
+var validSynchronousModes = map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+var validCacheModes = map[string]bool{"shared": true, "private": true}
+
+// returns the sqlite busy-timeout, synchronous- and cache-mode with the defaults applied
+func (o *ConnectionOptions) sqliteParams() (int, string, string, error) {
+	timeout, sync, cache := DEFAULT_SQLITE_BUSY_TIMEOUT, DEFAULT_SQLITE_SYNCHRONOUS, DEFAULT_SQLITE_CACHE_MODE
+	if o == nil {
+		return timeout, sync, cache, nil
+	}
+
+	if o.BusyTimeout > 0 {
+		timeout = o.BusyTimeout
+	}
+	if o.Synchronous != "" {
+		sync = strings.ToUpper(o.Synchronous)
+		if !validSynchronousModes[sync] {
+			return 0, "", "", fmt.Errorf("invalid sqlite synchronous mode: '%s'", o.Synchronous)
+		}
+	}
+	if o.CacheMode != "" {
+		cache = strings.ToLower(o.CacheMode)
+		if !validCacheModes[cache] {
+			return 0, "", "", fmt.Errorf("invalid sqlite cache mode: '%s'", o.CacheMode)
+		}
+	}
+	return timeout, sync, cache, nil
+}
+
+// applies the pool settings to the given database
+func (o *ConnectionOptions) apply(db *sql.DB) {
+	if o == nil {
+		return
+	}
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	if o.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	}
+}
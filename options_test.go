@@ -0,0 +1,172 @@
+package mtdb_test
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/mod_storage"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	wc := map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:    worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_AUTH_BACKEND:   worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_PLAYER_BACKEND: worldconfig.BACKEND_SQLITE3,
+	}
+
+	repos, err := mtdb.NewWithConfig(tmpdir, wc, &mtdb.Options{
+		Map: &mtdb.ConnectionOptions{
+			BusyTimeout:     1000,
+			Synchronous:     "full",
+			CacheMode:       "private",
+			MaxOpenConns:    2,
+			MaxIdleConns:    1,
+			ConnMaxLifetime: time.Minute,
+			ConnMaxIdleTime: time.Second * 10,
+		},
+		Auth: &mtdb.ConnectionOptions{MaxOpenConns: 1},
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, repos)
+	assert.NotNil(t, repos.Blocks)
+	repoSmokeTests(t, repos)
+	repos.Close()
+
+	// invalid options
+	_, err = mtdb.NewWithConfig(tmpdir, wc, &mtdb.Options{Auth: &mtdb.ConnectionOptions{Synchronous: "sometimes"}})
+	assert.Error(t, err)
+
+	_, err = mtdb.NewWithConfig(tmpdir, wc, &mtdb.Options{Player: &mtdb.ConnectionOptions{CacheMode: "xy"}})
+	assert.Error(t, err)
+}
+
+func TestNewWithMinetestConf(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	// dummy map backend in minetest.conf, overridden for the auth database in world.mt
+	err = os.WriteFile(path.Join(tmpdir, "minetest.conf"), []byte(`
+backend = dummy
+auth_backend = dummy
+motd = """
+hello
+"""
+`), 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(path.Join(tmpdir, "world.mt"), []byte("auth_backend = sqlite3\nplayer_backend = sqlite3\n"), 0644)
+	assert.NoError(t, err)
+
+	repos, err := mtdb.New(tmpdir, &mtdb.Options{MinetestConf: path.Join(tmpdir, "minetest.conf")})
+	assert.NoError(t, err)
+	assert.NotNil(t, repos)
+	defer repos.Close()
+	assert.Nil(t, repos.Blocks)
+	assert.NotNil(t, repos.Auth)
+	assert.NotNil(t, repos.Player)
+
+	// missing minetest.conf
+	_, err = mtdb.New(tmpdir, &mtdb.Options{MinetestConf: path.Join(tmpdir, "nonexistent.conf")})
+	assert.Error(t, err)
+}
+
+func TestNewWithOverrides(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	// dummy backends in world.mt
+	err = os.WriteFile(path.Join(tmpdir, "world.mt"), []byte("backend = dummy\nauth_backend = dummy\nplayer_backend = dummy\n"), 0644)
+	assert.NoError(t, err)
+
+	// environment takes precedence over world.mt
+	t.Setenv("MTDBTEST_AUTH_BACKEND", worldconfig.BACKEND_SQLITE3)
+	t.Setenv("MTDBTEST_PLAYER_BACKEND", "dummy")
+
+	repos, err := mtdb.New(tmpdir, &mtdb.Options{
+		EnvPrefix: "MTDBTEST_",
+		// overrides take precedence over the environment
+		Overrides: map[string]string{
+			worldconfig.CONFIG_PLAYER_BACKEND: worldconfig.BACKEND_SQLITE3,
+		},
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, repos)
+	defer repos.Close()
+	assert.Nil(t, repos.Blocks)
+	assert.NotNil(t, repos.Auth)
+	assert.NotNil(t, repos.Player)
+
+	// block-db only
+	t.Setenv("MTDBTEST_BACKEND", worldconfig.BACKEND_SQLITE3)
+	blocks, err := mtdb.NewBlockDB(tmpdir, &mtdb.Options{EnvPrefix: "MTDBTEST_"})
+	assert.NoError(t, err)
+	assert.NotNil(t, blocks)
+	blocks.Close()
+
+	// environment ignored without prefix
+	blocks, err = mtdb.NewBlockDB(tmpdir)
+	assert.NoError(t, err)
+	assert.Nil(t, blocks)
+
+	// multiple options are merged in order
+	wc, err := mtdb.LoadConfig(tmpdir,
+		&mtdb.Options{EnvPrefix: "MTDBTEST_", Overrides: map[string]string{"a": "1", "b": "1"}},
+		nil,
+		&mtdb.Options{Overrides: map[string]string{"b": "2"}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, worldconfig.BACKEND_SQLITE3, wc[worldconfig.CONFIG_MAP_BACKEND])
+	assert.Equal(t, "1", wc["a"])
+	assert.Equal(t, "2", wc["b"])
+}
+
+func TestNewWithModStorageAudit(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	wc := map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:         worldconfig.BACKEND_DUMMY,
+		worldconfig.CONFIG_AUTH_BACKEND:        worldconfig.BACKEND_DUMMY,
+		worldconfig.CONFIG_PLAYER_BACKEND:      worldconfig.BACKEND_DUMMY,
+		worldconfig.CONFIG_MOD_STORAGE_BACKEND: worldconfig.BACKEND_SQLITE3,
+	}
+	repos, err := mtdb.NewWithConfig(tmpdir, wc, &mtdb.Options{ModStorageAuditActor: "test"})
+	assert.NoError(t, err)
+	defer repos.Close()
+
+	audit, ok := repos.ModStorage.(*mod_storage.AuditRepository)
+	assert.True(t, ok)
+	assert.NoError(t, repos.ModStorage.Set(&mod_storage.ModStorageEntry{ModName: "m", Key: []byte("k"), Value: []byte("v")}))
+	history, err := audit.History("m", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, "test", history[0].Actor)
+}
+
+func TestNewWithWatchBlocks(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path.Join(tmpdir, "world.mt"), []byte("backend = sqlite3"), 0644))
+
+	// triggers not installed
+	blocks, err := mtdb.NewBlockDB(tmpdir)
+	assert.NoError(t, err)
+	_, _, err = blocks.Watch()
+	assert.Error(t, err)
+	assert.NoError(t, blocks.Close())
+
+	// opt-in
+	blocks, err = mtdb.NewBlockDB(tmpdir, &mtdb.Options{WatchBlocks: true})
+	assert.NoError(t, err)
+	defer blocks.Close()
+	_, closer, err := blocks.Watch()
+	assert.NoError(t, err)
+	closer.Close()
+}
@@ -14,8 +14,14 @@ func NewPlayerRepository(db *sql.DB, dbtype types.DatabaseType) *PlayerRepositor
 }
 
 type PlayerRepository struct {
-	db     *sql.DB
-	dbtype types.DatabaseType
+	db       *sql.DB
+	dbtype   types.DatabaseType
+	readonly bool
+}
+
+// SetReadOnly enables or disables the read-only mode, write operations return types.ErrReadOnly if enabled
+func (r *PlayerRepository) SetReadOnly(readonly bool) {
+	r.readonly = readonly
 }
 
 func (r *PlayerRepository) GetPlayer(name string) (*Player, error) {
@@ -30,6 +36,9 @@ func (r *PlayerRepository) GetPlayer(name string) (*Player, error) {
 }
 
 func (r *PlayerRepository) CreateOrUpdate(p *Player) error {
+	if r.readonly {
+		return types.ErrReadOnly
+	}
 	var q string
 	switch r.dbtype {
 	case types.DATABASE_SQLITE:
@@ -87,6 +96,9 @@ func (r *PlayerRepository) CreateOrUpdate(p *Player) error {
 }
 
 func (r *PlayerRepository) RemovePlayer(name string) error {
+	if r.readonly {
+		return types.ErrReadOnly
+	}
 	_, err := r.db.Exec("delete from player where name = $1", name)
 	return err
 }
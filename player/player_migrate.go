@@ -3,14 +3,17 @@ package player
 import (
 	"database/sql"
 
+	"github.com/minetest-go/mtdb/schema"
 	"github.com/minetest-go/mtdb/types"
 )
 
-func MigratePlayerDB(db *sql.DB, dbtype types.DatabaseType) error {
-	var err error
-	switch dbtype {
-	case types.DATABASE_SQLITE:
-		_, err = db.Exec(`
+// Migrations contains the numbered schema migrations of the player database,
+// the initial schema is compatible with the tables created by the engine
+var Migrations = &schema.Set{
+	Name: "player",
+	Migrations: map[types.DatabaseType][]*schema.Migration{
+		types.DATABASE_SQLITE: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`
 			CREATE TABLE IF NOT EXISTS player(
 				name VARCHAR(50) NOT NULL,
 				pitch NUMERIC(11, 4) NOT NULL,
@@ -48,9 +51,10 @@ func MigratePlayerDB(db *sql.DB, dbtype types.DatabaseType) error {
 				PRIMARY KEY(player, inv_id, slot_id),
 				FOREIGN KEY (player) REFERENCES player (name) ON DELETE CASCADE
 			);
-		`)
-	case types.DATABASE_POSTGRES:
-		_, err = db.Exec(`
+		`)},
+		},
+		types.DATABASE_POSTGRES: {
+			{Version: 1, Description: "initial schema", Up: schema.Exec(`
 			CREATE TABLE IF NOT EXISTS player (
 				name VARCHAR(60) NOT NULL,
 				pitch NUMERIC(15, 7) NOT NULL,
@@ -91,7 +95,11 @@ func MigratePlayerDB(db *sql.DB, dbtype types.DatabaseType) error {
 				CONSTRAINT player_metadata_fkey FOREIGN KEY (player) REFERENCES 
 				player (name) ON DELETE CASCADE
 			);
-		`)
-	}
-	return err
+		`)},
+		},
+	},
+}
+
+func MigratePlayerDB(db *sql.DB, dbtype types.DatabaseType) error {
+	return Migrations.Migrate(db, dbtype)
 }
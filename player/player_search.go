@@ -5,6 +5,9 @@ import (
 	"strings"
 )
 
+// number of players fetched per query in Iterate
+var IterateBatchSize = 1000
+
 func (repo *PlayerRepository) buildWhereClause(fields string, s *PlayerSearch) (string, []any) {
 	q := `select ` + fields + ` from player where true `
 	args := make([]any, 0)
@@ -22,6 +25,17 @@ func (repo *PlayerRepository) buildWhereClause(fields string, s *PlayerSearch) (
 		i++
 	}
 
+	if s.AfterName != nil {
+		q += fmt.Sprintf(" and name > $%d", i)
+		args = append(args, *s.AfterName)
+		i++
+	}
+
+	return q, args
+}
+
+func (repo *PlayerRepository) buildOrderClause(s *PlayerSearch) string {
+	q := ""
 	if s.OrderColumn != nil && orderColumns[*s.OrderColumn] {
 		order := Ascending
 		if s.OrderDirection != nil && orderDirections[*s.OrderDirection] {
@@ -29,6 +43,8 @@ func (repo *PlayerRepository) buildWhereClause(fields string, s *PlayerSearch) (
 		}
 
 		q += fmt.Sprintf(" order by %s %s", *s.OrderColumn, order)
+	} else if s.AfterName != nil {
+		q += " order by name asc"
 	}
 
 	// limit result length to 1000 per default
@@ -38,15 +54,36 @@ func (repo *PlayerRepository) buildWhereClause(fields string, s *PlayerSearch) (
 	}
 	q += fmt.Sprintf(" limit %d", limit)
 
-	return q, args
+	return q
+}
+
+// checks that the keyset cursor is only used with the ascending name order
+func (s *PlayerSearch) checkCursor() error {
+	if s.AfterName == nil {
+		return nil
+	}
+	if s.OrderColumn != nil && *s.OrderColumn != Name {
+		return fmt.Errorf("the cursor requires the '%s' order column, got '%s'", Name, *s.OrderColumn)
+	}
+	if s.OrderDirection != nil && *s.OrderDirection != Ascending {
+		return fmt.Errorf("the cursor requires the ascending order, got '%s'", *s.OrderDirection)
+	}
+	return nil
 }
 
 func (repo *PlayerRepository) Search(s *PlayerSearch) ([]*Player, error) {
+	err := s.checkCursor()
+	if err != nil {
+		return nil, err
+	}
 	q, args := repo.buildWhereClause(strings.Join(getColumns(repo.dbtype), ","), s)
+	q += repo.buildOrderClause(s)
 	rows, err := repo.db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
 	list := make([]*Player, 0)
 	for rows.Next() {
 		p, err := scanPlayer(rows.Scan)
@@ -55,7 +92,38 @@ func (repo *PlayerRepository) Search(s *PlayerSearch) ([]*Player, error) {
 		}
 		list = append(list, p)
 	}
-	return list, nil
+	return list, rows.Err()
+}
+
+// Iterate calls fn for every player matching the search filters in ascending name order, starting after s.AfterName if set.
+// The players are fetched in batches of IterateBatchSize, the limit and order of the search are ignored.
+// Iteration stops at the first error returned by fn
+func (repo *PlayerRepository) Iterate(s *PlayerSearch, fn func(*Player) error) error {
+	col := Name
+	dir := Ascending
+	limit := IterateBatchSize
+
+	page := *s
+	page.OrderColumn = &col
+	page.OrderDirection = &dir
+	page.Limit = &limit
+
+	for {
+		list, err := repo.Search(&page)
+		if err != nil {
+			return err
+		}
+		for _, p := range list {
+			err = fn(p)
+			if err != nil {
+				return err
+			}
+		}
+		if len(list) < limit {
+			return nil
+		}
+		page.AfterName = &list[len(list)-1].Name
+	}
 }
 
 func (repo *PlayerRepository) Count(s *PlayerSearch) (int, error) {
@@ -97,6 +97,42 @@ func testPlayerSearch(t *testing.T, repo *player.PlayerRepository) {
 	assert.NotNil(t, res)
 	assert.Equal(t, 0, len(res))
 
+	// search after name
+	res, err = repo.Search(&player.PlayerSearch{
+		AfterName: ref("player1"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "player2", res[0].Name)
+
+	// the cursor requires the name order
+	col := player.ModificationDate
+	_, err = repo.Search(&player.PlayerSearch{AfterName: ref("player1"), OrderColumn: &col})
+	assert.Error(t, err)
+	dir := player.Descending
+	_, err = repo.Search(&player.PlayerSearch{AfterName: ref("player1"), OrderDirection: &dir})
+	assert.Error(t, err)
+
+	// iterate in small batches
+	defer func(size int) { player.IterateBatchSize = size }(player.IterateBatchSize)
+	player.IterateBatchSize = 1
+	names := []string{}
+	err = repo.Iterate(&player.PlayerSearch{Namelike: ref("player%")}, func(p *player.Player) error {
+		names = append(names, p.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"player1", "player2"}, names)
+
+	// iterate after name
+	names = []string{}
+	err = repo.Iterate(&player.PlayerSearch{AfterName: ref("player1")}, func(p *player.Player) error {
+		names = append(names, p.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"player2"}, names)
+
 	// delete
 	assert.NoError(t, repo.RemovePlayer("player1"))
 	assert.NoError(t, repo.RemovePlayer("player2"))
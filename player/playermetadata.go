@@ -12,8 +12,14 @@ func NewPlayerMetadataRepository(db *sql.DB, dbtype types.DatabaseType) *PlayerM
 }
 
 type PlayerMetadataRepository struct {
-	db     *sql.DB
-	dbtype types.DatabaseType
+	db       *sql.DB
+	dbtype   types.DatabaseType
+	readonly bool
+}
+
+// SetReadOnly enables or disables the read-only mode, write operations return types.ErrReadOnly if enabled
+func (r *PlayerMetadataRepository) SetReadOnly(readonly bool) {
+	r.readonly = readonly
 }
 
 func (r *PlayerMetadataRepository) GetPlayerMetadata(name string) ([]*PlayerMetadata, error) {
@@ -44,6 +50,9 @@ func (r *PlayerMetadataRepository) GetPlayerMetadata(name string) ([]*PlayerMeta
 }
 
 func (r *PlayerMetadataRepository) SetPlayerMetadata(md *PlayerMetadata) error {
+	if r.readonly {
+		return types.ErrReadOnly
+	}
 	var q string
 	switch r.dbtype {
 	case types.DATABASE_SQLITE:
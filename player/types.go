@@ -39,6 +39,9 @@ type PlayerSearch struct {
 	Limit          *int                `json:"limit"`
 	OrderColumn    *OrderColumnType    `json:"order_column"`
 	OrderDirection *OrderDirectionType `json:"order_direction"`
+	// keyset cursor, only players after the given name are returned ordered by name.
+	// Search rejects the cursor combined with another order column or a descending order
+	AfterName *string `json:"after_name"`
 }
 
 type PlayerMetadata struct {
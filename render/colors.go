@@ -0,0 +1,66 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Colors maps node-names to their top-down color
+type Colors map[string]color.RGBA
+
+// ParseColors parses a color table in the minetestmapper "colors.txt" format:
+//
+//	# comment
+//	default:stone 128 128 128
+//	default:water_source 39 66 106 128 224
+//
+// the optional alpha and the unused fifth value are accepted, empty lines and comments are ignored
+func ParseColors(r io.Reader) (Colors, error) {
+	colors := Colors{}
+	scanner := bufio.NewScanner(r)
+	linenum := 0
+	for scanner.Scan() {
+		linenum++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 || len(fields) > 6 {
+			return nil, fmt.Errorf("invalid color definition in line %d: '%s'", linenum, line)
+		}
+
+		values := []uint8{0, 0, 0, 255}
+		for i, field := range fields[1:] {
+			if i > 3 {
+				// unused "t" value
+				break
+			}
+			v, err := strconv.ParseUint(field, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid color value in line %d: %v", linenum, err)
+			}
+			values[i] = uint8(v)
+		}
+		colors[fields[0]] = color.RGBA{R: values[0], G: values[1], B: values[2], A: values[3]}
+	}
+	return colors, scanner.Err()
+}
+
+// LoadColors reads a color table from the given file
+func LoadColors(filename string) (Colors, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseColors(file)
+}
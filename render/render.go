@@ -0,0 +1,104 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/sirupsen/logrus"
+)
+
+// Renderer creates top-down images of the map
+type Renderer struct {
+	repo   block.BlockRepository
+	colors Colors
+}
+
+func NewRenderer(repo block.BlockRepository, colors Colors) *Renderer {
+	return &Renderer{repo: repo, colors: colors}
+}
+
+// Render creates a top-down image of the given mapblock area, every node is one pixel.
+// The x-axis points to the right and the z-axis points up, nodes without a color-definition
+// (like "air") are transparent and the alpha channel of the colors is ignored
+func (r *Renderer) Render(area *block.Area) (*image.RGBA, error) {
+	width := (area.Max.X - area.Min.X + 1) * 16
+	height := (area.Max.Z - area.Min.Z + 1) * 16
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// highest rendered node per pixel
+	heightmap := make([]int, width*height)
+	for i := range heightmap {
+		heightmap[i] = math.MinInt
+	}
+
+	err := block.IterateArea(r.repo, area, nil, func(b *block.Block) error {
+		m, err := block.ParseMapblock(b.Data)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"pos": []int{b.PosX, b.PosY, b.PosZ},
+				"err": err,
+			}).Warn("invalid mapblock")
+			return nil
+		}
+
+		// resolve the colors per node-id once
+		colors := map[uint16]color.RGBA{}
+		for id, name := range m.Mapping {
+			c, found := r.colors[name]
+			if found {
+				c.A = 255
+				colors[id] = c
+			}
+		}
+		if len(colors) == 0 {
+			return nil
+		}
+
+		for z := 0; z < 16; z++ {
+			for x := 0; x < 16; x++ {
+				px := (b.PosX-area.Min.X)*16 + x
+				py := height - 1 - ((b.PosZ-area.Min.Z)*16 + z)
+				i := px + py*width
+
+				for y := 15; y >= 0; y-- {
+					ny := b.PosY*16 + y
+					if ny <= heightmap[i] {
+						// already rendered a higher node
+						break
+					}
+					c, found := colors[m.Param0[block.NodeIndex(x, y, z)]]
+					if found {
+						img.SetRGBA(px, py, c)
+						heightmap[i] = ny
+						break
+					}
+				}
+			}
+		}
+		return nil
+	})
+
+	return img, err
+}
+
+// RenderPNG renders the area and writes it as png image
+func (r *Renderer) RenderPNG(area *block.Area, w io.Writer) error {
+	img, err := r.Render(area)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// TileArea returns the mapblock area of the tile at the given tile-coordinates,
+// a tile spans tilesize*tilesize mapblocks on the x/z axes and all mapblocks from ymin to ymax
+func TileArea(tx, tz, tilesize, ymin, ymax int) *block.Area {
+	return &block.Area{
+		Min: block.Pos{X: tx * tilesize, Y: ymin, Z: tz * tilesize},
+		Max: block.Pos{X: (tx+1)*tilesize - 1, Y: ymax, Z: (tz+1)*tilesize - 1},
+	}
+}
@@ -0,0 +1,81 @@
+package render_test
+
+import (
+	"bytes"
+	"database/sql"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/minetest-go/mtdb/block"
+	"github.com/minetest-go/mtdb/render"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func openMap(t *testing.T) block.BlockRepository {
+	dbfile, err := os.CreateTemp(os.TempDir(), "map.sqlite")
+	assert.NoError(t, err)
+	src, err := os.Open("../block/testdata/map_legacy_column.sqlite")
+	assert.NoError(t, err)
+	defer src.Close()
+	_, err = io.Copy(dbfile, src)
+	assert.NoError(t, err)
+	assert.NoError(t, dbfile.Close())
+
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	repo, err := block.NewBlockRepository(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	return repo
+}
+
+func TestParseColors(t *testing.T) {
+	colors, err := render.LoadColors("testdata/colors.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(colors))
+	assert.Equal(t, color.RGBA{R: 128, G: 128, B: 128, A: 255}, colors["nc_terrain:stone"])
+	assert.Equal(t, color.RGBA{R: 39, G: 66, B: 106, A: 128}, colors["nc_terrain:water_source"])
+	assert.Equal(t, color.RGBA{R: 214, G: 207, B: 158, A: 255}, colors["nc_terrain:sand"])
+
+	_, err = render.ParseColors(strings.NewReader("default:stone 1 2"))
+	assert.Error(t, err)
+	_, err = render.ParseColors(strings.NewReader("default:stone 1 2 300"))
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	repo := openMap(t)
+	defer repo.Close()
+
+	colors, err := render.LoadColors("testdata/colors.txt")
+	assert.NoError(t, err)
+	r := render.NewRenderer(repo, colors)
+
+	area := render.TileArea(-1, -1, 2, -4, 4)
+	img, err := r.Render(area)
+	assert.NoError(t, err)
+	assert.Equal(t, 32, img.Bounds().Dx())
+	assert.Equal(t, 32, img.Bounds().Dy())
+
+	colored := 0
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			if img.RGBAAt(x, y).A > 0 {
+				colored++
+			}
+		}
+	}
+	assert.True(t, colored > 0)
+
+	buf := bytes.NewBuffer([]byte{})
+	assert.NoError(t, r.RenderPNG(area, buf))
+	decoded, err := png.Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, img.Bounds(), decoded.Bounds())
+}
@@ -0,0 +1,187 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/minetest-go/mtdb/types"
+	"github.com/sirupsen/logrus"
+)
+
+// VersionTable is the name of the table that records the applied migrations,
+// one row per database kind and version
+const VersionTable = "mtdb_schema_version"
+
+// Migration is a single numbered schema change
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// Exec returns a migration function that executes the given sql statements
+func Exec(stmts string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmts)
+		return err
+	}
+}
+
+// Set is the list of migrations per backend for a database kind ("auth", "blocks", ...),
+// the database kind allows multiple sets to share a single (postgres) database
+type Set struct {
+	Name       string
+	Migrations map[types.DatabaseType][]*Migration
+}
+
+// Status is the migration state of a database kind
+type Status struct {
+	Name string `json:"name"`
+	// highest applied version, 0 if nothing is applied yet
+	Current int `json:"current"`
+	// highest available version
+	Latest int `json:"latest"`
+	// versions not yet applied
+	Pending []int `json:"pending"`
+}
+
+func createVersionTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + VersionTable + ` (
+		name VARCHAR(64) NOT NULL,
+		version INT NOT NULL,
+		description TEXT NOT NULL,
+		applied BIGINT NOT NULL,
+		PRIMARY KEY (name, version)
+	)`)
+	return err
+}
+
+func versionTableExists(db *sql.DB, dbtype types.DatabaseType) (bool, error) {
+	var q string
+	switch dbtype {
+	case types.DATABASE_POSTGRES:
+		q = "select count(*) from information_schema.tables where table_schema = current_schema() and table_name = $1"
+	default:
+		q = "select count(*) from sqlite_master where type = 'table' and name = $1"
+	}
+	count := 0
+	err := db.QueryRow(q, VersionTable).Scan(&count)
+	return count > 0, err
+}
+
+// CurrentVersion returns the highest applied version of the set, 0 if nothing is applied yet
+func (s *Set) CurrentVersion(db *sql.DB, dbtype types.DatabaseType) (int, error) {
+	exists, err := versionTableExists(db, dbtype)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err = db.QueryRow("select max(version) from "+VersionTable+" where name = $1", s.Name).Scan(&version)
+	return int(version.Int64), err
+}
+
+// Status returns the current and pending versions of the set without modifying the database
+func (s *Set) Status(db *sql.DB, dbtype types.DatabaseType) (*Status, error) {
+	current, err := s.CurrentVersion(db, dbtype)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Name: s.Name, Current: current, Pending: []int{}}
+	for _, m := range s.Migrations[dbtype] {
+		status.Latest = max(status.Latest, m.Version)
+		if m.Version > current {
+			status.Pending = append(status.Pending, m.Version)
+		}
+	}
+	return status, nil
+}
+
+// Migrate applies all pending migrations of the set in order, every migration runs in its own transaction.
+// Concurrent migrations of the same database are serialized, already applied versions are skipped
+func (s *Set) Migrate(db *sql.DB, dbtype types.DatabaseType) error {
+	migrations := s.Migrations[dbtype]
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	err := createVersionTable(db)
+	if err != nil {
+		return fmt.Errorf("version table creation failed: %v", err)
+	}
+
+	current, err := s.CurrentVersion(db, dbtype)
+	if err != nil {
+		return err
+	}
+
+	previous := 0
+	for _, m := range migrations {
+		if m.Version <= previous {
+			return fmt.Errorf("migration %s/%d is out of order", s.Name, m.Version)
+		}
+		previous = m.Version
+		if m.Version <= current {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"name":        s.Name,
+			"version":     m.Version,
+			"description": m.Description,
+		}).Debug("Applying migration")
+
+		err = s.apply(db, dbtype, m)
+		if err != nil {
+			return fmt.Errorf("migration %s/%d (%s) failed: %v", s.Name, m.Version, m.Description, err)
+		}
+	}
+	return nil
+}
+
+// applies the migration in a transaction, the migration is skipped if another process applied it concurrently
+func (s *Set) apply(db *sql.DB, dbtype types.DatabaseType, m *Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = lockVersionTable(tx, dbtype, s.Name)
+	if err != nil {
+		return err
+	}
+	count := 0
+	err = tx.QueryRow("select count(*) from "+VersionTable+" where name = $1 and version = $2", s.Name, m.Version).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		logrus.WithFields(logrus.Fields{"name": s.Name, "version": m.Version}).Debug("Migration already applied")
+		return nil
+	}
+
+	err = m.Up(tx)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("insert into "+VersionTable+"(name, version, description, applied) values($1, $2, $3, $4)",
+		s.Name, m.Version, m.Description, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// serializes the migrations of the set until the end of the transaction
+func lockVersionTable(tx *sql.Tx, dbtype types.DatabaseType, name string) error {
+	var err error
+	switch dbtype {
+	case types.DATABASE_POSTGRES:
+		_, err = tx.Exec("select pg_advisory_xact_lock(hashtext($1))", VersionTable+"/"+name)
+	default:
+		// a write as the first statement takes the sqlite write lock (waiting for the busy timeout)
+		_, err = tx.Exec("update "+VersionTable+" set applied = applied where name = $1 and version < 0", name)
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package schema_test
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/minetest-go/mtdb/schema"
+	"github.com/minetest-go/mtdb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	dbfile, err := os.CreateTemp(os.TempDir(), "schema.sqlite")
+	assert.NoError(t, err)
+	db, err := sql.Open("sqlite3", "file:"+dbfile.Name())
+	assert.NoError(t, err)
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	set := &schema.Set{
+		Name: "test",
+		Migrations: map[types.DatabaseType][]*schema.Migration{
+			types.DATABASE_SQLITE: {
+				{Version: 1, Description: "table", Up: schema.Exec("create table if not exists x (id int)")},
+				{Version: 2, Description: "index", Up: schema.Exec("create index if not exists x_id on x (id)")},
+			},
+		},
+	}
+
+	// nothing applied, version table not created
+	status, err := set.Status(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	assert.Equal(t, &schema.Status{Name: "test", Current: 0, Latest: 2, Pending: []int{1, 2}}, status)
+
+	assert.NoError(t, set.Migrate(db, types.DATABASE_SQLITE))
+	status, err = set.Status(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	assert.Equal(t, &schema.Status{Name: "test", Current: 2, Latest: 2, Pending: []int{}}, status)
+
+	// idempotent
+	assert.NoError(t, set.Migrate(db, types.DATABASE_SQLITE))
+
+	// failing migration is rolled back
+	set.Migrations[types.DATABASE_SQLITE] = append(set.Migrations[types.DATABASE_SQLITE],
+		&schema.Migration{Version: 3, Description: "broken", Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table y (id int)")
+			assert.NoError(t, err)
+			return errors.New("failed")
+		}},
+	)
+	assert.Error(t, set.Migrate(db, types.DATABASE_SQLITE))
+	version, err := set.CurrentVersion(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+	_, err = db.Exec("select * from y")
+	assert.Error(t, err)
+
+	// other sets in the same database are independent
+	other := &schema.Set{Name: "other"}
+	version, err = other.CurrentVersion(db, types.DATABASE_SQLITE)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+}
+
+func TestMigrateOutOfOrder(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	set := &schema.Set{
+		Name: "test",
+		Migrations: map[types.DatabaseType][]*schema.Migration{
+			types.DATABASE_SQLITE: {
+				{Version: 2, Description: "b", Up: schema.Exec("select 1")},
+				{Version: 1, Description: "a", Up: schema.Exec("select 1")},
+			},
+		},
+	}
+	assert.Error(t, set.Migrate(db, types.DATABASE_SQLITE))
+}
+
+func TestMigrateConcurrent(t *testing.T) {
+	dbfile, err := os.CreateTemp(os.TempDir(), "schema.sqlite")
+	assert.NoError(t, err)
+
+	// the first run blocks until released, the migration fails if applied twice
+	started := make(chan bool)
+	release := make(chan bool)
+	calls := 0
+	set := &schema.Set{
+		Name: "test",
+		Migrations: map[types.DatabaseType][]*schema.Migration{
+			types.DATABASE_SQLITE: {
+				{Version: 1, Description: "table", Up: func(tx *sql.Tx) error {
+					calls++
+					if calls == 1 {
+						started <- true
+						<-release
+					}
+					_, err := tx.Exec("create table x (id int)")
+					return err
+				}},
+			},
+		},
+	}
+
+	// separate connection pools, like separate processes
+	migrate := func(errs chan error) {
+		db, err := sql.Open("sqlite3", "file:"+dbfile.Name()+"?_timeout=10000")
+		if err == nil {
+			err = set.Migrate(db, types.DATABASE_SQLITE)
+			db.Close()
+		}
+		errs <- err
+	}
+
+	errs1 := make(chan error)
+	go migrate(errs1)
+	<-started
+
+	// the second process sees the pending migration and waits for the lock
+	errs2 := make(chan error)
+	go migrate(errs2)
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	assert.NoError(t, <-errs1)
+	assert.NoError(t, <-errs2)
+	assert.Equal(t, 1, calls)
+}
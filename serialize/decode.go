@@ -0,0 +1,463 @@
+package serialize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decode parses the output of minetest.serialize() into go values:
+//
+//	nil -> nil, booleans -> bool, integers -> int64, other numbers -> float64, strings -> string,
+//	tables with consecutive integer keys starting at 1 -> []any, other tables -> map[any]any
+//
+// Only the literal subset produced by the serializer is accepted (no function calls or
+// variables except the "_" reference table), the data is never executed
+func Decode(data []byte) (any, error) {
+	p := &parser{lexer: &lexer{data: string(data)}, refs: map[int64]*table{}}
+	v, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("decode error at offset %d: %v", p.lexer.pos, err)
+	}
+	return toGo(v, map[*table]any{}), nil
+}
+
+// intermediate table representation, resolved into go values after parsing
+type table struct {
+	keys   []any
+	values map[any]any
+}
+
+func newTable() *table {
+	return &table{values: map[any]any{}}
+}
+
+func (t *table) set(key, value any) {
+	if _, found := t.values[key]; !found {
+		t.keys = append(t.keys, key)
+	}
+	t.values[key] = value
+}
+
+type parser struct {
+	lexer *lexer
+	// shared tables of the "local _ = {}" preamble
+	refs map[int64]*table
+	tok  token
+}
+
+func (p *parser) next() error {
+	var err error
+	p.tok, err = p.lexer.next()
+	return err
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	if p.tok.kind != kind || (value != "" && p.tok.value != value) {
+		return fmt.Errorf("expected '%s', got '%s'", value, p.tok.value)
+	}
+	return p.next()
+}
+
+func (p *parser) parse() (any, error) {
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokenName && p.tok.value == "local" {
+		err = p.parsePreamble()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.tok.kind == tokenName && p.tok.value == "return" {
+		err = p.next()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var v any
+	if p.tok.kind != tokenEOF {
+		v, err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected '%s' after value", p.tok.value)
+	}
+	return v, nil
+}
+
+// skips an optional ";" statement separator
+func (p *parser) skipSemicolon() error {
+	if p.tok.kind == tokenSymbol && p.tok.value == ";" {
+		return p.next()
+	}
+	return nil
+}
+
+// parses the reference preamble: "local _ = {} _[1] = {...} _[1]["key"] = _[2] ..."
+func (p *parser) parsePreamble() error {
+	for _, expected := range []struct {
+		kind  tokenKind
+		value string
+	}{{tokenName, "local"}, {tokenName, "_"}, {tokenSymbol, "="}, {tokenSymbol, "{"}, {tokenSymbol, "}"}} {
+		err := p.expect(expected.kind, expected.value)
+		if err != nil {
+			return err
+		}
+	}
+	err := p.skipSemicolon()
+	if err != nil {
+		return err
+	}
+
+	for p.tok.kind == tokenName && p.tok.value == "_" {
+		err := p.next()
+		if err != nil {
+			return err
+		}
+
+		// reference index and optional nested keys
+		keys := []any{}
+		for p.tok.kind == tokenSymbol && p.tok.value == "[" {
+			err = p.next()
+			if err != nil {
+				return err
+			}
+			key, err := p.parseValue()
+			if err == nil {
+				key, err = tableKey(key)
+			}
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+			err = p.expect(tokenSymbol, "]")
+			if err != nil {
+				return err
+			}
+		}
+		err = p.expect(tokenSymbol, "=")
+		if err != nil {
+			return err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		err = p.skipSemicolon()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return fmt.Errorf("missing reference index")
+		}
+		index, ok := keys[0].(int64)
+		if !ok {
+			return fmt.Errorf("invalid reference index: %v", keys[0])
+		}
+		if len(keys) == 1 {
+			t, ok := value.(*table)
+			if !ok {
+				return fmt.Errorf("reference %d is not a table", index)
+			}
+			p.refs[index] = t
+			continue
+		}
+
+		// nested assignment into a reference
+		t := p.refs[index]
+		if t == nil {
+			return fmt.Errorf("unknown reference: %d", index)
+		}
+		for _, key := range keys[1 : len(keys)-1] {
+			t, ok = t.values[key].(*table)
+			if !ok {
+				return fmt.Errorf("invalid nested assignment into reference %d", index)
+			}
+		}
+		t.set(keys[len(keys)-1], value)
+	}
+	return nil
+}
+
+// validates a table key, integral floats are normalized to integers like in lua ([1.0] and [1] are the same key)
+func tableKey(key any) (any, error) {
+	switch k := key.(type) {
+	case nil:
+		return nil, fmt.Errorf("nil table key")
+	case *table:
+		return nil, fmt.Errorf("table keys are not supported")
+	case float64:
+		if math.IsNaN(k) {
+			return nil, fmt.Errorf("nan table key")
+		}
+		if k == math.Trunc(k) && k >= math.MinInt64 && k < math.MaxInt64 {
+			return int64(k), nil
+		}
+	}
+	return key, nil
+}
+
+func (p *parser) parseValue() (any, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenString:
+		return tok.value, p.next()
+	case tokenNumber:
+		return p.parseNumber(false)
+	case tokenSymbol:
+		switch tok.value {
+		case "{":
+			return p.parseTable()
+		case "-":
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if p.tok.kind == tokenName && p.tok.value == "math" {
+				v, err := p.parseMathHuge()
+				return -v, err
+			}
+			if p.tok.kind != tokenNumber {
+				return nil, fmt.Errorf("expected number after '-', got '%s'", p.tok.value)
+			}
+			return p.parseNumber(true)
+		case "(":
+			// parenthesized value, for example "(0/0)"
+			err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			return v, p.expect(tokenSymbol, ")")
+		}
+	case tokenName:
+		switch tok.value {
+		case "nil":
+			return nil, p.next()
+		case "true":
+			return true, p.next()
+		case "false":
+			return false, p.next()
+		case "math":
+			return p.parseMathHuge()
+		case "_":
+			return p.parseReference()
+		}
+	}
+	return nil, fmt.Errorf("unexpected '%s'", tok.value)
+}
+
+func (p *parser) parseMathHuge() (float64, error) {
+	for _, value := range []string{"math", ".", "huge"} {
+		err := p.expect(p.tok.kind, value)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return math.Inf(1), nil
+}
+
+// parses a number literal with an optional literal divisor ("0/0", "1/0")
+func (p *parser) parseNumber(negative bool) (any, error) {
+	v, err := parseNumberLiteral(p.tok.value, negative)
+	if err != nil {
+		return nil, err
+	}
+	err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenSymbol || p.tok.value != "/" {
+		return v, nil
+	}
+
+	err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenNumber {
+		return nil, fmt.Errorf("expected number after '/', got '%s'", p.tok.value)
+	}
+	d, err := parseNumberLiteral(p.tok.value, false)
+	if err != nil {
+		return nil, err
+	}
+	return toFloat(v) / toFloat(d), p.next()
+}
+
+func toFloat(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func parseNumberLiteral(s string, negative bool) (any, error) {
+	if negative {
+		s = "-" + s
+	}
+	lower := strings.ToLower(s)
+	if strings.Contains(lower, "0x") {
+		if !strings.ContainsAny(lower, ".p") {
+			i, err := strconv.ParseInt(s, 0, 64)
+			if err == nil {
+				return i, nil
+			}
+		}
+	} else if !strings.ContainsAny(lower, ".e") {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: '%s'", s)
+	}
+	return f, nil
+}
+
+func (p *parser) parseReference() (any, error) {
+	err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expect(tokenSymbol, "[")
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenNumber {
+		return nil, fmt.Errorf("invalid reference index: '%s'", p.tok.value)
+	}
+	index, err := strconv.ParseInt(p.tok.value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference index: '%s'", p.tok.value)
+	}
+	t := p.refs[index]
+	if t == nil {
+		return nil, fmt.Errorf("unknown reference: %d", index)
+	}
+	err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	return t, p.expect(tokenSymbol, "]")
+}
+
+func (p *parser) parseTable() (any, error) {
+	err := p.expect(tokenSymbol, "{")
+	if err != nil {
+		return nil, err
+	}
+	t := newTable()
+	var index int64 = 1
+
+	for !(p.tok.kind == tokenSymbol && p.tok.value == "}") {
+		var key any
+		switch {
+		case p.tok.kind == tokenSymbol && p.tok.value == "[":
+			// [key] = value
+			err = p.next()
+			if err != nil {
+				return nil, err
+			}
+			key, err = p.parseValue()
+			if err == nil {
+				key, err = tableKey(key)
+			}
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect(tokenSymbol, "]")
+			if err == nil {
+				err = p.expect(tokenSymbol, "=")
+			}
+		case p.tok.kind == tokenName && p.lexer.peekSymbol() == "=":
+			// name = value
+			key = p.tok.value
+			err = p.next()
+			if err == nil {
+				err = p.expect(tokenSymbol, "=")
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			// positional value
+			key = index
+			index++
+		}
+		if value != nil {
+			t.set(key, value)
+		}
+
+		if p.tok.kind == tokenSymbol && (p.tok.value == "," || p.tok.value == ";") {
+			err = p.next()
+			if err != nil {
+				return nil, err
+			}
+		} else if !(p.tok.kind == tokenSymbol && p.tok.value == "}") {
+			return nil, fmt.Errorf("expected ',' or '}', got '%s'", p.tok.value)
+		}
+	}
+	return t, p.next()
+}
+
+// converts the intermediate tables into go values, shared tables are converted only once
+func toGo(v any, seen map[*table]any) any {
+	t, ok := v.(*table)
+	if !ok {
+		return v
+	}
+	if converted, found := seen[t]; found {
+		return converted
+	}
+
+	if isSequence(t) {
+		list := make([]any, len(t.values))
+		seen[t] = list
+		for i := range list {
+			list[i] = toGo(t.values[int64(i+1)], seen)
+		}
+		return list
+	}
+
+	m := make(map[any]any, len(t.values))
+	seen[t] = m
+	for _, key := range t.keys {
+		// keys are validated by tableKey() and never tables
+		m[key] = toGo(t.values[key], seen)
+	}
+	return m
+}
+
+// returns true if the table has only the integer keys 1 to n
+func isSequence(t *table) bool {
+	if len(t.values) == 0 {
+		return false
+	}
+	for i := 1; i <= len(t.values); i++ {
+		if _, found := t.values[int64(i)]; !found {
+			return false
+		}
+	}
+	return true
+}
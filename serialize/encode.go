@@ -0,0 +1,202 @@
+package serialize
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Encode serializes the go value in the format of minetest.serialize(): "return <value>".
+// Supported are nil, booleans, numbers, strings, byte slices (as strings), slices/arrays (as sequences)
+// and maps with string, number or boolean keys, map keys are sorted for a stable output.
+// Shared or recursive tables are not supported
+func Encode(v any) ([]byte, error) {
+	buf := bytes.NewBufferString("return ")
+	err := encodeValue(buf, reflect.ValueOf(v), map[uintptr]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value, visiting map[uintptr]bool) error {
+	if !v.IsValid() {
+		buf.WriteString("nil")
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return nil
+		}
+		return encodeValue(buf, v.Elem(), visiting)
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		buf.WriteString(formatNumber(v.Float()))
+	case reflect.String:
+		writeString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			writeString(buf, string(v.Bytes()))
+			return nil
+		}
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				buf.WriteString("nil")
+				return nil
+			}
+			if visiting[v.Pointer()] && v.Len() > 0 {
+				return fmt.Errorf("recursive tables are not supported")
+			}
+			visiting[v.Pointer()] = true
+			defer delete(visiting, v.Pointer())
+		}
+		buf.WriteString("{")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			err := encodeValue(buf, v.Index(i), visiting)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteString("nil")
+			return nil
+		}
+		if visiting[v.Pointer()] {
+			return fmt.Errorf("recursive tables are not supported")
+		}
+		visiting[v.Pointer()] = true
+		defer delete(visiting, v.Pointer())
+		return encodeMap(buf, v, visiting)
+	default:
+		return fmt.Errorf("unsupported type: %s", v.Type())
+	}
+	return nil
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+	// sort order: numbers, strings, booleans
+	group  int
+	number float64
+}
+
+func encodeMap(buf *bytes.Buffer, v reflect.Value, visiting map[uintptr]bool) error {
+	entries := []*mapEntry{}
+	iter := v.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		for k.Kind() == reflect.Interface && !k.IsNil() {
+			k = k.Elem()
+		}
+
+		e := &mapEntry{value: iter.Value()}
+		keybuf := &bytes.Buffer{}
+		switch k.Kind() {
+		case reflect.String:
+			e.group = 1
+			writeString(keybuf, k.String())
+		case reflect.Bool:
+			e.group = 2
+			keybuf.WriteString(strconv.FormatBool(k.Bool()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			e.number = float64(k.Int())
+			keybuf.WriteString(strconv.FormatInt(k.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			e.number = float64(k.Uint())
+			keybuf.WriteString(strconv.FormatUint(k.Uint(), 10))
+		case reflect.Float32, reflect.Float64:
+			if math.IsNaN(k.Float()) {
+				return fmt.Errorf("NaN table keys are not supported")
+			}
+			e.number = k.Float()
+			keybuf.WriteString(formatNumber(k.Float()))
+		default:
+			return fmt.Errorf("unsupported table key type: %s", k.Type())
+		}
+		e.key = keybuf.String()
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if a.group == 0 {
+			return a.number < b.number
+		}
+		return a.key < b.key
+	})
+
+	buf.WriteString("{")
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("[")
+		buf.WriteString(e.key)
+		buf.WriteString("] = ")
+		err := encodeValue(buf, e.value, visiting)
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// formats the number like the engine does (%.17g), with special values for NaN and infinity
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "0/0"
+	case math.IsInf(f, 1):
+		return "math.huge"
+	case math.IsInf(f, -1):
+		return "-math.huge"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(f, 'g', 17, 64)
+	}
+}
+
+// writes the string quoted like lua's "%q" format
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString("\\n")
+		case c == '\r':
+			buf.WriteString("\\r")
+		case c < 0x20 || c == 0x7f:
+			// pad to 3 digits to avoid ambiguity with following digits
+			fmt.Fprintf(buf, "\\%03d", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+}
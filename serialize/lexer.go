@@ -0,0 +1,266 @@
+package serialize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+type lexer struct {
+	data string
+	pos  int
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skips whitespace and comments
+func (l *lexer) skip() error {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			l.pos++
+		case strings.HasPrefix(l.data[l.pos:], "--"):
+			l.pos += 2
+			if level := longBracketLevel(l.data[l.pos:]); level >= 0 {
+				_, err := l.readLongString(level)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			end := strings.IndexByte(l.data[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.data)
+			} else {
+				l.pos += end + 1
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// returns the next symbol without consuming it or an empty string
+func (l *lexer) peekSymbol() string {
+	pos := l.pos
+	defer func() { l.pos = pos }()
+	tok, err := l.next()
+	if err != nil || tok.kind != tokenSymbol {
+		return ""
+	}
+	return tok.value
+}
+
+func (l *lexer) next() (token, error) {
+	err := l.skip()
+	if err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.data) {
+		return token{kind: tokenEOF, value: "<eof>"}, nil
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case isNameStart(c):
+		start := l.pos
+		for l.pos < len(l.data) && (isNameStart(l.data[l.pos]) || isDigit(l.data[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: l.data[start:l.pos]}, nil
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.data) && isDigit(l.data[l.pos+1])):
+		return l.readNumber(), nil
+
+	case c == '"' || c == '\'':
+		s, err := l.readString(c)
+		return token{kind: tokenString, value: s}, err
+
+	case c == '[':
+		if level := longBracketLevel(l.data[l.pos:]); level >= 0 {
+			s, err := l.readLongString(level)
+			return token{kind: tokenString, value: s}, err
+		}
+	}
+
+	if strings.ContainsRune("{}[]()=,;-/.", rune(c)) {
+		l.pos++
+		return token{kind: tokenSymbol, value: string(c)}, nil
+	}
+	return token{}, fmt.Errorf("unexpected character '%c'", c)
+}
+
+func (l *lexer) readNumber() token {
+	start := l.pos
+	hex := strings.HasPrefix(strings.ToLower(l.data[l.pos:]), "0x")
+	if hex {
+		l.pos += 2
+	}
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		exponent := (!hex && (c == 'e' || c == 'E')) || (hex && (c == 'p' || c == 'P'))
+		switch {
+		case exponent:
+			l.pos++
+			if l.pos < len(l.data) && (l.data[l.pos] == '+' || l.data[l.pos] == '-') {
+				l.pos++
+			}
+		case isDigit(c) || c == '.' || (hex && strings.ContainsRune("abcdefABCDEF", rune(c))):
+			l.pos++
+		default:
+			return token{kind: tokenNumber, value: l.data[start:l.pos]}
+		}
+	}
+	return token{kind: tokenNumber, value: l.data[start:l.pos]}
+}
+
+// reads a quoted string with escape sequences
+func (l *lexer) readString(quote byte) (string, error) {
+	l.pos++
+	sb := strings.Builder{}
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case quote:
+			return sb.String(), nil
+		case '\n':
+			return "", fmt.Errorf("unfinished string")
+		case '\\':
+			err := l.readEscape(&sb)
+			if err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unfinished string")
+}
+
+var simpleEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '"': '"', '\'': '\'', '\n': '\n',
+}
+
+func (l *lexer) readEscape(sb *strings.Builder) error {
+	if l.pos >= len(l.data) {
+		return fmt.Errorf("unfinished string")
+	}
+	c := l.data[l.pos]
+	l.pos++
+
+	if r, found := simpleEscapes[c]; found {
+		sb.WriteByte(r)
+		if c == '\n' && l.pos < len(l.data) && l.data[l.pos] == '\r' {
+			l.pos++
+		}
+		return nil
+	}
+
+	switch {
+	case c == '\r':
+		// escaped windows newline
+		sb.WriteByte('\n')
+		if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+			l.pos++
+		}
+	case isDigit(c):
+		// decimal escape, up to 3 digits
+		start := l.pos - 1
+		for l.pos < len(l.data) && l.pos-start < 3 && isDigit(l.data[l.pos]) {
+			l.pos++
+		}
+		v, _ := strconv.Atoi(l.data[start:l.pos])
+		if v > 255 {
+			return fmt.Errorf("decimal escape too large: %d", v)
+		}
+		sb.WriteByte(byte(v))
+	case c == 'x':
+		if l.pos+2 > len(l.data) {
+			return fmt.Errorf("invalid hex escape")
+		}
+		v, err := strconv.ParseUint(l.data[l.pos:l.pos+2], 16, 8)
+		if err != nil {
+			return fmt.Errorf("invalid hex escape")
+		}
+		l.pos += 2
+		sb.WriteByte(byte(v))
+	case c == 'z':
+		// skip the following whitespace
+		for l.pos < len(l.data) && strings.ContainsRune(" \t\n\r\f\v", rune(l.data[l.pos])) {
+			l.pos++
+		}
+	case c == 'u':
+		end := strings.IndexByte(l.data[l.pos:], '}')
+		if !strings.HasPrefix(l.data[l.pos:], "{") || end < 0 {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		v, err := strconv.ParseUint(l.data[l.pos+1:l.pos+end], 16, 32)
+		if err != nil || v > utf8.MaxRune {
+			return fmt.Errorf("invalid unicode escape")
+		}
+		l.pos += end + 1
+		sb.WriteRune(rune(v))
+	default:
+		return fmt.Errorf("invalid escape sequence '\\%c'", c)
+	}
+	return nil
+}
+
+// returns the level of the long bracket ("[[" -> 0, "[==[" -> 2) or -1 if there is none
+func longBracketLevel(s string) int {
+	if !strings.HasPrefix(s, "[") {
+		return -1
+	}
+	level := 0
+	for level+1 < len(s) && s[level+1] == '=' {
+		level++
+	}
+	if level+1 < len(s) && s[level+1] == '[' {
+		return level
+	}
+	return -1
+}
+
+// reads a long string ("[[...]]", "[==[...]==]"), a leading newline is skipped
+func (l *lexer) readLongString(level int) (string, error) {
+	l.pos += level + 2
+	closing := "]" + strings.Repeat("=", level) + "]"
+	end := strings.Index(l.data[l.pos:], closing)
+	if end < 0 {
+		return "", fmt.Errorf("unfinished long string")
+	}
+	s := l.data[l.pos : l.pos+end]
+	l.pos += end + len(closing)
+	if strings.HasPrefix(s, "\r\n") {
+		s = s[2:]
+	} else if strings.HasPrefix(s, "\n") {
+		s = s[1:]
+	}
+	return s, nil
+}
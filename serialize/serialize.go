@@ -0,0 +1,48 @@
+// Package serialize decodes and encodes the values of minetest.serialize() and
+// minetest.write_json(), as found in the mod storage and metadata
+package serialize
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+type Format string
+
+const (
+	// minetest.serialize() output
+	FORMAT_LUA Format = "lua"
+	// minetest.write_json() output
+	FORMAT_JSON Format = "json"
+	// anything else (plain strings, numbers)
+	FORMAT_RAW Format = "raw"
+)
+
+// DetectFormat returns the probable format of the serialized value
+func DetectFormat(data []byte) Format {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("return")) || bytes.HasPrefix(trimmed, []byte("local _ =")):
+		return FORMAT_LUA
+	case (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) && json.Valid(trimmed):
+		return FORMAT_JSON
+	default:
+		return FORMAT_RAW
+	}
+}
+
+// DecodeValue decodes the value in the detected format, raw values are returned as string
+func DecodeValue(data []byte) (any, Format, error) {
+	format := DetectFormat(data)
+	switch format {
+	case FORMAT_LUA:
+		v, err := Decode(data)
+		return v, format, err
+	case FORMAT_JSON:
+		var v any
+		err := json.Unmarshal(data, &v)
+		return v, format, err
+	default:
+		return string(data), format, nil
+	}
+}
@@ -0,0 +1,181 @@
+package serialize_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/minetest-go/mtdb/serialize"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	// mod storage fixture
+	v, err := serialize.Decode([]byte(`return {["singleplayer"] = {["waypoints"] = {}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"singleplayer": map[any]any{"waypoints": map[any]any{}}}, v)
+
+	tests := map[string]any{
+		`return nil`:                       nil,
+		`return true`:                      true,
+		`return false`:                     false,
+		`return 42`:                        int64(42),
+		`return -42`:                       int64(-42),
+		`return 010`:                       int64(10),
+		`return 0x1F`:                      int64(31),
+		`return 1.5`:                       1.5,
+		`return -1.5e3`:                    -1500.0,
+		`return 0.10000000000000001`:       0.1,
+		`return math.huge`:                 math.Inf(1),
+		`return -math.huge`:                math.Inf(-1),
+		`return 1/0`:                       math.Inf(1),
+		`return -1/0`:                      math.Inf(-1),
+		`return "a\"b\\c\nd\0e\65\x41"`:    "a\"b\\c\nd\x00eAA",
+		"return \"multi\\\nline\"":         "multi\nline",
+		`return 'single'`:                  "single",
+		"return [[long\n]]":                "long\n",
+		"return [==[\n]]x]==]":             "]]x",
+		`return {1, 2, "three"}`:           []any{int64(1), int64(2), "three"},
+		`return {[1] = 1, [2] = 2}`:        []any{int64(1), int64(2)},
+		`return {[1.0] = 1, [2e0] = 2}`:    []any{int64(1), int64(2)},
+		`return {[-3.0] = 1}`:              map[any]any{int64(-3): int64(1)},
+		`return {[2] = 2}`:                 map[any]any{int64(2): int64(2)},
+		`return {a = 1; b = {}, }`:         map[any]any{"a": int64(1), "b": map[any]any{}},
+		`return {[true] = "x", [1.5] = 2}`: map[any]any{true: "x", 1.5: int64(2)},
+		`return {x = nil, 1}`:              []any{int64(1)},
+		"-- comment\nreturn {} -- end":     map[any]any{},
+	}
+	for input, expected := range tests {
+		v, err := serialize.Decode([]byte(input))
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, v, input)
+	}
+
+	v, err = serialize.Decode([]byte(`return 0/0`))
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(v.(float64)))
+}
+
+func TestDecodeReferences(t *testing.T) {
+	// shared and recursive tables
+	v, err := serialize.Decode([]byte(`local _ = {}
+_[1] = {["x"] = 1}
+_[2] = {}
+_[2]["self"] = _[2]
+return {["a"] = _[1], ["b"] = _[1], ["c"] = _[2]}`))
+	assert.NoError(t, err)
+	m := v.(map[any]any)
+	assert.Equal(t, map[any]any{"x": int64(1)}, m["a"])
+	assert.Equal(t, m["a"], m["b"])
+	c := m["c"].(map[any]any)
+	c["marker"] = true
+	assert.Equal(t, true, c["self"].(map[any]any)["marker"])
+}
+
+func TestDecodeReferencesSemicolons(t *testing.T) {
+	v, err := serialize.Decode([]byte(`local _={};_[1]={};return {_[1],_[1]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{map[any]any{}, map[any]any{}}, v)
+
+	v, err = serialize.Decode([]byte(`local _ = {}; _[1] = {["x"] = 1}; _[1]["y"] = 2; return _[1]`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"x": int64(1), "y": int64(2)}, v)
+}
+
+func TestDecodeReferenceKeys(t *testing.T) {
+	// integral float keys in nested reference assignments
+	v, err := serialize.Decode([]byte(`local _ = {}
+_[1] = {}
+_[1][1.0] = "a"
+_[1][2] = "b"
+return _[1]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"a", "b"}, v)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, input := range []string{
+		`return os.execute("rm -rf /")`,
+		`return {`,
+		`return {[nil] = 1}`,
+		`return {[{}] = 1}`,
+		`return "unfinished`,
+		`return 1 2`,
+		`return _[1]`,
+		`return x`,
+		`local _ = {} _[1] = 5 return 1`,
+		`local _ = {} _[1] = {} _[1][{}] = 1 return _[1]`,
+		`local _ = {} _[1] = {} _[1][_[1]] = 1 return _[1]`,
+		`local _ = {} _[1] = {} _[1][nil] = 1 return _[1]`,
+		`return {[0/0] = 1}`,
+		`return "\q"`,
+		`return "\300"`,
+	} {
+		_, err := serialize.Decode([]byte(input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := map[string]any{
+		`return nil`:                 nil,
+		`return true`:                true,
+		`return 42`:                  42,
+		`return 1.5`:                 1.5,
+		`return 3`:                   3.0,
+		`return 0.10000000000000001`: 0.1,
+		`return math.huge`:           math.Inf(1),
+		`return 0/0`:                 math.NaN(),
+		`return "a\"b\\c\nd\000e"`:   "a\"b\\c\nd\x00e",
+		`return "bytes"`:             []byte("bytes"),
+		`return {1, "two", {}}`:      []any{1, "two", []any{}},
+		`return {[1] = "a", [2.5] = "b", ["x"] = true, ["y"] = {["z"] = 1}, [true] = 0}`: map[any]any{
+			1: "a", 2.5: "b", "x": true, "y": map[string]int{"z": 1}, true: 0,
+		},
+	}
+	for expected, input := range tests {
+		data, err := serialize.Encode(input)
+		assert.NoError(t, err, expected)
+		assert.Equal(t, expected, string(data))
+	}
+
+	_, err := serialize.Encode(func() {})
+	assert.Error(t, err)
+	_, err = serialize.Encode(map[any]any{[2]int{1, 2}: 1})
+	assert.Error(t, err)
+
+	recursive := map[string]any{}
+	recursive["self"] = recursive
+	_, err = serialize.Encode(recursive)
+	assert.Error(t, err)
+}
+
+func TestRoundtrip(t *testing.T) {
+	input := map[any]any{
+		"name":   "singleplayer",
+		"pos":    map[any]any{"x": 1.5, "y": int64(-2), "z": int64(3)},
+		"items":  []any{"default:stone 99", "default:dirt", "weird \x01\"\\ string\n"},
+		int64(5): false,
+	}
+	data, err := serialize.Encode(input)
+	assert.NoError(t, err)
+	v, err := serialize.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, input, v)
+}
+
+func TestDecodeValue(t *testing.T) {
+	v, format, err := serialize.DecodeValue([]byte(`return {1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, serialize.FORMAT_LUA, format)
+	assert.Equal(t, []any{int64(1)}, v)
+
+	v, format, err = serialize.DecodeValue([]byte(`{"a": [1, 2]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, serialize.FORMAT_JSON, format)
+	assert.Equal(t, map[string]any{"a": []any{1.0, 2.0}}, v)
+
+	v, format, err = serialize.DecodeValue([]byte(`123`))
+	assert.NoError(t, err)
+	assert.Equal(t, serialize.FORMAT_RAW, format)
+	assert.Equal(t, "123", v)
+}
@@ -0,0 +1,6 @@
+package types
+
+import "errors"
+
+// ErrReadOnly is returned by the write operations of repositories in read-only mode
+var ErrReadOnly = errors.New("database is opened in read-only mode")
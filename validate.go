@@ -0,0 +1,247 @@
+package mtdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minetest-go/mtdb/types"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/sirupsen/logrus"
+)
+
+type ValidationSeverity string
+
+const (
+	SEVERITY_ERROR   ValidationSeverity = "error"
+	SEVERITY_WARNING ValidationSeverity = "warning"
+)
+
+// database names used in the validation issues
+const (
+	DATABASE_MAP         = "map"
+	DATABASE_AUTH        = "auth"
+	DATABASE_PLAYER      = "player"
+	DATABASE_MOD_STORAGE = "mod_storage"
+)
+
+// ValidationIssue is a single problem found in the world configuration
+type ValidationIssue struct {
+	Severity ValidationSeverity `json:"severity"`
+	// affected database, see DATABASE_*
+	Database string `json:"database"`
+	// affected setting in the world.mt
+	Setting string `json:"setting"`
+	Message string `json:"message"`
+}
+
+func (i *ValidationIssue) Error() string {
+	return fmt.Sprintf("%s: %s database, setting '%s': %s", i.Severity, i.Database, i.Setting, i.Message)
+}
+
+// ValidationIssues is the result of a configuration validation
+type ValidationIssues []*ValidationIssue
+
+// Errors returns only the issues with error severity
+func (issues ValidationIssues) Errors() ValidationIssues {
+	return issues.filter(SEVERITY_ERROR)
+}
+
+// Warnings returns only the issues with warning severity
+func (issues ValidationIssues) Warnings() ValidationIssues {
+	return issues.filter(SEVERITY_WARNING)
+}
+
+func (issues ValidationIssues) filter(severity ValidationSeverity) ValidationIssues {
+	result := ValidationIssues{}
+	for _, issue := range issues {
+		if issue.Severity == severity {
+			result = append(result, issue)
+		}
+	}
+	return result
+}
+
+// Err returns all issues with error severity joined as a single error or nil if there are none
+func (issues ValidationIssues) Err() error {
+	errs := []error{}
+	for _, issue := range issues.Errors() {
+		errs = append(errs, issue)
+	}
+	return errors.Join(errs...)
+}
+
+// configuration of a single database in the world.mt
+type databaseConfig struct {
+	name          string
+	backendKey    string
+	connectionKey string
+	sqliteFile    string
+	// backends supported by mtdb
+	supported []string
+	// backends supported by the engine but not by mtdb
+	unsupported []string
+	// an unsupported or unknown backend only skips the database when opening a context
+	optional bool
+}
+
+var mapDatabaseConfig = &databaseConfig{
+	name:          DATABASE_MAP,
+	backendKey:    worldconfig.CONFIG_MAP_BACKEND,
+	connectionKey: worldconfig.CONFIG_PSQL_MAP_CONNECTION,
+	sqliteFile:    "map.sqlite",
+	supported:     []string{worldconfig.BACKEND_SQLITE3, worldconfig.BACKEND_POSTGRES, worldconfig.BACKEND_DUMMY},
+	unsupported:   []string{worldconfig.BACKEND_LEVELDB, worldconfig.BACKEND_REDIS},
+	optional:      true,
+}
+
+var authDatabaseConfig = &databaseConfig{
+	name:          DATABASE_AUTH,
+	backendKey:    worldconfig.CONFIG_AUTH_BACKEND,
+	connectionKey: worldconfig.CONFIG_PSQL_AUTH_CONNECTION,
+	sqliteFile:    "auth.sqlite",
+	supported:     []string{worldconfig.BACKEND_SQLITE3, worldconfig.BACKEND_POSTGRES, worldconfig.BACKEND_DUMMY},
+	unsupported:   []string{worldconfig.BACKEND_FILES, worldconfig.BACKEND_LEVELDB},
+	optional:      true,
+}
+
+var modStorageDatabaseConfig = &databaseConfig{
+	name:          DATABASE_MOD_STORAGE,
+	backendKey:    worldconfig.CONFIG_MOD_STORAGE_BACKEND,
+	connectionKey: worldconfig.CONFIG_PSQL_MOD_STORAGE_CONNECTION,
+	sqliteFile:    "mod_storage.sqlite",
+	supported:     []string{worldconfig.BACKEND_SQLITE3, worldconfig.BACKEND_POSTGRES, worldconfig.BACKEND_DUMMY},
+	unsupported:   []string{worldconfig.BACKEND_FILES},
+	optional:      true,
+}
+
+var playerDatabaseConfig = &databaseConfig{
+	name:          DATABASE_PLAYER,
+	backendKey:    worldconfig.CONFIG_PLAYER_BACKEND,
+	connectionKey: worldconfig.CONFIG_PSQL_PLAYER_CONNECTION,
+	sqliteFile:    "players.sqlite",
+	supported:     []string{worldconfig.BACKEND_SQLITE3, worldconfig.BACKEND_POSTGRES, worldconfig.BACKEND_DUMMY},
+	unsupported:   []string{worldconfig.BACKEND_FILES, worldconfig.BACKEND_LEVELDB},
+	optional:      true,
+}
+
+var databaseConfigs = []*databaseConfig{
+	mapDatabaseConfig,
+	authDatabaseConfig,
+	modStorageDatabaseConfig,
+	playerDatabaseConfig,
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the backend opened by a context, optional databases with an unsupported backend are skipped
+func (d *databaseConfig) contextBackend(wc map[string]string) types.DatabaseType {
+	if d.optional && !d.supportedBackend(wc) {
+		return types.DATABASE_DUMMY
+	}
+	return types.DatabaseType(wc[d.backendKey])
+}
+
+// returns true if the configured backend is supported (or not set)
+func (d *databaseConfig) supportedBackend(wc map[string]string) bool {
+	backend := wc[d.backendKey]
+	return backend == "" || contains(d.supported, backend)
+}
+
+func (d *databaseConfig) validate(world_dir string, wc map[string]string, readonly bool) ValidationIssues {
+	issues := ValidationIssues{}
+	add := func(severity ValidationSeverity, setting, format string, args ...any) {
+		issues = append(issues, &ValidationIssue{
+			Severity: severity,
+			Database: d.name,
+			Setting:  setting,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	backend := wc[d.backendKey]
+	switch {
+	case backend == "":
+		// defaults to sqlite3
+		backend = worldconfig.BACKEND_SQLITE3
+	case contains(d.unsupported, backend):
+		add(SEVERITY_ERROR, d.backendKey, "backend '%s' is not supported, use one of: %s",
+			backend, strings.Join(d.supported, ", "))
+		return issues
+	case !contains(d.supported, backend):
+		add(SEVERITY_ERROR, d.backendKey, "unknown backend '%s', use one of: %s",
+			backend, strings.Join(d.supported, ", "))
+		return issues
+	}
+
+	switch types.DatabaseType(backend) {
+	case types.DATABASE_POSTGRES:
+		if wc[d.connectionKey] == "" {
+			add(SEVERITY_ERROR, d.connectionKey, "missing connection string for the '%s' backend", backend)
+		}
+	case types.DATABASE_SQLITE:
+		if readonly {
+			filename := path.Join(world_dir, d.sqliteFile)
+			_, err := os.Stat(filename)
+			if errors.Is(err, os.ErrNotExist) {
+				add(SEVERITY_WARNING, d.backendKey, "database file '%s' not found, the database is not available in read-only mode", filename)
+			}
+		}
+	}
+
+	return issues
+}
+
+// ValidateConfig checks the backend configuration of all databases in the given (resolved) config
+func ValidateConfig(world_dir string, wc map[string]string, readonly bool) ValidationIssues {
+	return validateDatabases(world_dir, wc, readonly, databaseConfigs)
+}
+
+func validateDatabases(world_dir string, wc map[string]string, readonly bool, dbs []*databaseConfig) ValidationIssues {
+	issues := ValidationIssues{}
+	for _, d := range dbs {
+		issues = append(issues, d.validate(world_dir, wc, readonly)...)
+	}
+	return issues
+}
+
+// validates the given databases, logs the warnings and returns the errors,
+// unsupported backends of optional databases are only logged, the database is skipped
+func checkConfig(world_dir string, wc map[string]string, readonly bool, dbs []*databaseConfig) error {
+	issues := ValidationIssues{}
+	for _, d := range dbs {
+		db_issues := d.validate(world_dir, wc, readonly)
+		if d.optional && !d.supportedBackend(wc) {
+			for _, issue := range db_issues {
+				issue.Severity = SEVERITY_WARNING
+				issue.Message += ", the database is skipped"
+			}
+		}
+		issues = append(issues, db_issues...)
+	}
+	for _, issue := range issues.Warnings() {
+		logrus.WithFields(logrus.Fields{
+			"database": issue.Database,
+			"setting":  issue.Setting,
+		}).Warn(issue.Message)
+	}
+	return issues.Err()
+}
+
+// LoadConfig parses the "world.mt" file in the world-dir and applies the settings from the optional options
+func LoadConfig(world_dir string, opts ...*Options) (map[string]string, error) {
+	wc, err := worldconfig.Parse(path.Join(world_dir, "world.mt"))
+	if err != nil {
+		return nil, err
+	}
+	return getOptions(opts).resolveConfig(wc)
+}
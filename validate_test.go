@@ -0,0 +1,121 @@
+package mtdb_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/minetest-go/mtdb"
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	// valid config
+	wc := map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:          worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_AUTH_BACKEND:         worldconfig.BACKEND_POSTGRES,
+		worldconfig.CONFIG_PSQL_AUTH_CONNECTION: "host=localhost",
+		worldconfig.CONFIG_PLAYER_BACKEND:       worldconfig.BACKEND_DUMMY,
+	}
+	issues := mtdb.ValidateConfig(tmpdir, wc, false)
+	assert.Equal(t, 0, len(issues))
+	assert.NoError(t, issues.Err())
+
+	// invalid config
+	wc = map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:         "sqlite",
+		worldconfig.CONFIG_AUTH_BACKEND:        worldconfig.BACKEND_FILES,
+		worldconfig.CONFIG_MOD_STORAGE_BACKEND: worldconfig.BACKEND_POSTGRES,
+	}
+	issues = mtdb.ValidateConfig(tmpdir, wc, true)
+	assert.Error(t, issues.Err())
+	assert.Equal(t, 3, len(issues.Errors()))
+	assert.Equal(t, 1, len(issues.Warnings()))
+
+	assert.Equal(t, mtdb.DATABASE_MAP, issues[0].Database)
+	assert.Equal(t, worldconfig.CONFIG_MAP_BACKEND, issues[0].Setting)
+	assert.Contains(t, issues[0].Message, "unknown backend 'sqlite'")
+
+	assert.Equal(t, mtdb.DATABASE_AUTH, issues[1].Database)
+	assert.Contains(t, issues[1].Message, "not supported")
+
+	assert.Equal(t, mtdb.DATABASE_MOD_STORAGE, issues[2].Database)
+	assert.Equal(t, worldconfig.CONFIG_PSQL_MOD_STORAGE_CONNECTION, issues[2].Setting)
+
+	// missing players.sqlite in read-only mode
+	assert.Equal(t, mtdb.SEVERITY_WARNING, issues[3].Severity)
+	assert.Equal(t, mtdb.DATABASE_PLAYER, issues[3].Database)
+
+	// context creation fails
+	_, err = mtdb.NewWithConfig(tmpdir, wc)
+	assert.Error(t, err)
+	_, err = mtdb.NewWithConfig(tmpdir, map[string]string{worldconfig.CONFIG_MAP_BACKEND: worldconfig.BACKEND_POSTGRES})
+	assert.Error(t, err)
+}
+
+func TestNewWithUnsupportedMapBackend(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+
+	wc := map[string]string{
+		worldconfig.CONFIG_MAP_BACKEND:    worldconfig.BACKEND_LEVELDB,
+		worldconfig.CONFIG_AUTH_BACKEND:   worldconfig.BACKEND_SQLITE3,
+		worldconfig.CONFIG_PLAYER_BACKEND: worldconfig.BACKEND_SQLITE3,
+	}
+
+	// reported by the validation
+	issues := mtdb.ValidateConfig(tmpdir, wc, false)
+	assert.Equal(t, 1, len(issues.Errors()))
+
+	// the map is skipped, the other databases are available
+	repos, err := mtdb.NewWithConfig(tmpdir, wc)
+	assert.NoError(t, err)
+	defer repos.Close()
+	assert.Nil(t, repos.Blocks)
+	assert.NotNil(t, repos.Auth)
+	assert.NotNil(t, repos.Player)
+	_, err = os.Stat(path.Join(tmpdir, "map.sqlite"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// block-db only
+	assert.NoError(t, os.WriteFile(path.Join(tmpdir, "world.mt"), []byte("backend = leveldb"), 0644))
+	blocks, err := mtdb.NewBlockDB(tmpdir)
+	assert.NoError(t, err)
+	assert.Nil(t, blocks)
+}
+
+func TestNewWithFilesBackends(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "mtdb")
+	assert.NoError(t, err)
+	contents := `
+backend = sqlite3
+auth_backend = files
+player_backend = files
+mod_storage_backend = files
+`
+	assert.NoError(t, os.WriteFile(path.Join(tmpdir, "world.mt"), []byte(contents), 0644))
+
+	// reported by the validation
+	wc, err := worldconfig.Parse(path.Join(tmpdir, "world.mt"))
+	assert.NoError(t, err)
+	issues := mtdb.ValidateConfig(tmpdir, wc, false)
+	assert.Equal(t, 3, len(issues.Errors()))
+
+	// the unsupported databases are skipped, the map is available
+	repos, err := mtdb.New(tmpdir)
+	assert.NoError(t, err)
+	defer repos.Close()
+	assert.NotNil(t, repos.Blocks)
+	assert.Nil(t, repos.Auth)
+	assert.Nil(t, repos.Privs)
+	assert.Nil(t, repos.Player)
+	assert.Nil(t, repos.ModStorage)
+	for _, name := range []string{"auth.sqlite", "players.sqlite", "mod_storage.sqlite"} {
+		_, err = os.Stat(path.Join(tmpdir, name))
+		assert.ErrorIs(t, err, os.ErrNotExist, name)
+	}
+}
@@ -0,0 +1,20 @@
+package worldconfig
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvSettings returns the settings from the environment variables with the given prefix,
+// the remaining variable name is lowercased: "MTDB_PGSQL_CONNECTION" -> "pgsql_connection"
+func EnvSettings(prefix string) map[string]string {
+	cfg := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		cfg[strings.ToLower(strings.TrimPrefix(name, prefix))] = value
+	}
+	return cfg
+}
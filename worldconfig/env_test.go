@@ -0,0 +1,20 @@
+package worldconfig_test
+
+import (
+	"testing"
+
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvSettings(t *testing.T) {
+	t.Setenv("MTDBTEST_PGSQL_CONNECTION", "host=localhost")
+	t.Setenv("MTDBTEST_BACKEND", "postgresql")
+	t.Setenv("MTDBTEST_", "ignored")
+
+	cfg := worldconfig.EnvSettings("MTDBTEST_")
+	assert.Equal(t, map[string]string{
+		worldconfig.CONFIG_PSQL_MAP_CONNECTION: "host=localhost",
+		worldconfig.CONFIG_MAP_BACKEND:         "postgresql",
+	}, cfg)
+}
@@ -0,0 +1,152 @@
+package worldconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Settings is the content of a minetest.conf file with its values and nested groups:
+//
+//	name = value
+//	motd = """
+//	multi-line
+//	value
+//	"""
+//	group = {
+//		key = value
+//	}
+type Settings struct {
+	Values map[string]string    `json:"values"`
+	Groups map[string]*Settings `json:"groups"`
+}
+
+func newSettings() *Settings {
+	return &Settings{
+		Values: map[string]string{},
+		Groups: map[string]*Settings{},
+	}
+}
+
+// ParseMinetestConf parses the minetest.conf format from the given reader
+func ParseMinetestConf(r io.Reader) (*Settings, error) {
+	scanner := &lineScanner{Scanner: bufio.NewScanner(r)}
+	s := newSettings()
+	ended, err := s.parse(scanner)
+	if err != nil {
+		return nil, err
+	}
+	if ended {
+		return nil, fmt.Errorf("line %d: unexpected '}' without a group", scanner.line)
+	}
+	return s, scanner.Err()
+}
+
+// LoadMinetestConf reads the settings from the given minetest.conf file
+func LoadMinetestConf(filename string) (*Settings, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseMinetestConf(file)
+}
+
+// scanner with the current line number
+type lineScanner struct {
+	*bufio.Scanner
+	line int
+}
+
+func (s *lineScanner) Scan() bool {
+	ok := s.Scanner.Scan()
+	if ok {
+		s.line++
+	}
+	return ok
+}
+
+// parses the settings until the end of the group or file, returns true if the group end was reached
+func (s *Settings) parse(scanner *lineScanner) (bool, error) {
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "}" {
+			return true, nil
+		}
+
+		sepIndex := strings.Index(line, "=")
+		if sepIndex < 0 {
+			// invalid line
+			continue
+		}
+		key := strings.TrimSpace(line[:sepIndex])
+		value := strings.TrimSpace(line[sepIndex+1:])
+
+		switch value {
+		case "{":
+			start := scanner.line
+			group := newSettings()
+			ended, err := group.parse(scanner)
+			if err != nil {
+				return false, err
+			}
+			if !ended && scanner.Err() == nil {
+				return false, fmt.Errorf("line %d: unterminated group '%s'", start, key)
+			}
+			s.Groups[key] = group
+		case `"""`:
+			start := scanner.line
+			lines := []string{}
+			terminated := false
+			for scanner.Scan() {
+				if strings.TrimSpace(scanner.Text()) == `"""` {
+					terminated = true
+					break
+				}
+				lines = append(lines, scanner.Text())
+			}
+			if !terminated && scanner.Err() == nil {
+				return false, fmt.Errorf("line %d: unterminated multi-line value '%s'", start, key)
+			}
+			s.Values[key] = strings.Join(lines, "\n")
+		default:
+			s.Values[key] = value
+		}
+	}
+	return false, nil
+}
+
+// Get returns the value of the setting or an empty string if not set
+func (s *Settings) Get(key string) string {
+	return s.Values[key]
+}
+
+// Group returns the nested group or nil if not set
+func (s *Settings) Group(key string) *Settings {
+	return s.Groups[key]
+}
+
+// ToMap returns the top-level values (without groups) in map form
+func (s *Settings) ToMap() map[string]string {
+	cfg := make(map[string]string, len(s.Values))
+	for k, v := range s.Values {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+// Merge combines the given settings maps into a new one, later maps take precedence
+func Merge(maps ...map[string]string) map[string]string {
+	cfg := make(map[string]string)
+	for _, m := range maps {
+		for k, v := range m {
+			cfg[k] = v
+		}
+	}
+	return cfg
+}
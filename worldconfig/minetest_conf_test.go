@@ -0,0 +1,54 @@
+package worldconfig_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMinetestConf(t *testing.T) {
+	s, err := worldconfig.LoadMinetestConf("testdata/minetest.conf")
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	assert.Equal(t, "My Server", s.Get("server_name"))
+	assert.Equal(t, "Welcome!\n  Have fun", s.Get("motd"))
+	assert.Equal(t, worldconfig.BACKEND_POSTGRES, s.Get(worldconfig.CONFIG_MAP_BACKEND))
+	assert.Equal(t, "15", s.Get("max_users"))
+
+	g := s.Group("mg_flags")
+	assert.NotNil(t, g)
+	assert.Equal(t, "true", g.Get("caves"))
+	assert.Equal(t, "1.5", g.Group("noise").Get("scale"))
+	assert.Nil(t, s.Group("nonexistent"))
+
+	m := s.ToMap()
+	assert.Equal(t, 5, len(m))
+	assert.Equal(t, "", m["caves"])
+
+	_, err = worldconfig.ParseMinetestConf(strings.NewReader("a = b\n}\n"))
+	assert.EqualError(t, err, "line 2: unexpected '}' without a group")
+
+	// unterminated group and multi-line value
+	_, err = worldconfig.ParseMinetestConf(strings.NewReader("a = b\ngroup = {\nc = d\nx = y\n"))
+	assert.EqualError(t, err, "line 2: unterminated group 'group'")
+
+	_, err = worldconfig.ParseMinetestConf(strings.NewReader("a = b\ngroup = {\nnested = {\n}\n"))
+	assert.EqualError(t, err, "line 2: unterminated group 'group'")
+
+	_, err = worldconfig.ParseMinetestConf(strings.NewReader("a = b\nmotd = \"\"\"\nline\nc = d\n"))
+	assert.EqualError(t, err, "line 2: unterminated multi-line value 'motd'")
+
+	_, err = worldconfig.LoadMinetestConf("testdata/nonexistent.conf")
+	assert.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	m := worldconfig.Merge(
+		map[string]string{"a": "1", "b": "1"},
+		map[string]string{"b": "2", "c": "2"},
+	)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2", "c": "2"}, m)
+}
@@ -0,0 +1,114 @@
+package worldconfig
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ModEntry is a "load_mod_<name>" setting
+type ModEntry struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// optional mod path if the value is a path ("load_mod_mymod = mods/mymod")
+	Path string `json:"path"`
+}
+
+func parseModEntry(name, value string) *ModEntry {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		// not a boolean: path to the enabled mod
+		return &ModEntry{Name: name, Enabled: value != "", Path: value}
+	}
+	return &ModEntry{Name: name, Enabled: enabled}
+}
+
+// GameID returns the "gameid" setting
+func (wc *WorldConfig) GameID() string {
+	return wc.Get(CONFIG_GAMEID)
+}
+
+func (wc *WorldConfig) SetGameID(gameid string) {
+	wc.Set(CONFIG_GAMEID, gameid)
+}
+
+// WorldName returns the "world_name" setting
+func (wc *WorldConfig) WorldName() string {
+	return wc.Get(CONFIG_WORLD_NAME)
+}
+
+func (wc *WorldConfig) SetWorldName(name string) {
+	wc.Set(CONFIG_WORLD_NAME, name)
+}
+
+// CreativeMode returns the "creative_mode" setting, defaults to false
+func (wc *WorldConfig) CreativeMode() bool {
+	return wc.GetBool(CONFIG_CREATIVE_MODE, false)
+}
+
+func (wc *WorldConfig) SetCreativeMode(enabled bool) {
+	wc.SetBool(CONFIG_CREATIVE_MODE, enabled)
+}
+
+// EnableDamage returns the "enable_damage" setting, defaults to true
+func (wc *WorldConfig) EnableDamage() bool {
+	return wc.GetBool(CONFIG_ENABLE_DAMAGE, true)
+}
+
+func (wc *WorldConfig) SetEnableDamage(enabled bool) {
+	wc.SetBool(CONFIG_ENABLE_DAMAGE, enabled)
+}
+
+// Mods returns all "load_mod_<name>" entries in file order
+func (wc *WorldConfig) Mods() []*ModEntry {
+	mods := []*ModEntry{}
+	for _, key := range wc.Keys() {
+		if strings.HasPrefix(key, CONFIG_LOAD_MOD_PREFIX) {
+			name := strings.TrimPrefix(key, CONFIG_LOAD_MOD_PREFIX)
+			mods = append(mods, parseModEntry(name, wc.Get(key)))
+		}
+	}
+	return mods
+}
+
+// EnabledMods returns the names of all enabled mods in file order
+func (wc *WorldConfig) EnabledMods() []string {
+	names := []string{}
+	for _, mod := range wc.Mods() {
+		if mod.Enabled {
+			names = append(names, mod.Name)
+		}
+	}
+	return names
+}
+
+// GetMod returns the entry of the given mod or nil if there is no "load_mod_<name>" setting
+func (wc *WorldConfig) GetMod(name string) *ModEntry {
+	key := CONFIG_LOAD_MOD_PREFIX + name
+	if !wc.Has(key) {
+		return nil
+	}
+	return parseModEntry(name, wc.Get(key))
+}
+
+// IsModEnabled returns true if the mod is enabled
+func (wc *WorldConfig) IsModEnabled(name string) bool {
+	mod := wc.GetMod(name)
+	return mod != nil && mod.Enabled
+}
+
+// EnableMod enables the mod, an existing mod path is kept
+func (wc *WorldConfig) EnableMod(name string) {
+	if !wc.IsModEnabled(name) {
+		wc.SetBool(CONFIG_LOAD_MOD_PREFIX+name, true)
+	}
+}
+
+// SetModPath enables the mod with the given path
+func (wc *WorldConfig) SetModPath(name, path string) {
+	wc.Set(CONFIG_LOAD_MOD_PREFIX+name, path)
+}
+
+// DisableMod disables the mod
+func (wc *WorldConfig) DisableMod(name string) {
+	wc.SetBool(CONFIG_LOAD_MOD_PREFIX+name, false)
+}
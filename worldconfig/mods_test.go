@@ -0,0 +1,62 @@
+package worldconfig_test
+
+import (
+	"testing"
+
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorldConfigGameSettings(t *testing.T) {
+	wc, err := worldconfig.Load("testdata/world.mt.comments")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "minetest", wc.GameID())
+	assert.Equal(t, "my world", wc.WorldName())
+	assert.False(t, wc.CreativeMode())
+	assert.True(t, wc.EnableDamage())
+
+	wc.SetGameID("mineclonia")
+	wc.SetWorldName("other")
+	wc.SetCreativeMode(true)
+	wc.SetEnableDamage(false)
+	assert.Equal(t, "mineclonia", wc.GameID())
+	assert.Equal(t, "other", wc.WorldName())
+	assert.True(t, wc.CreativeMode())
+	assert.False(t, wc.EnableDamage())
+
+	// defaults
+	wc = worldconfig.NewWorldConfig()
+	assert.False(t, wc.CreativeMode())
+	assert.True(t, wc.EnableDamage())
+	assert.Equal(t, "", wc.GameID())
+}
+
+func TestWorldConfigMods(t *testing.T) {
+	wc, err := worldconfig.Load("testdata/world.mt.comments")
+	assert.NoError(t, err)
+
+	mods := wc.Mods()
+	assert.Equal(t, []*worldconfig.ModEntry{
+		{Name: "mesecons", Enabled: true},
+		{Name: "technic", Enabled: false},
+		{Name: "mymod", Enabled: true, Path: "mods/mymod"},
+	}, mods)
+	assert.Equal(t, []string{"mesecons", "mymod"}, wc.EnabledMods())
+
+	assert.True(t, wc.IsModEnabled("mesecons"))
+	assert.False(t, wc.IsModEnabled("technic"))
+	assert.False(t, wc.IsModEnabled("nonexistent"))
+	assert.Nil(t, wc.GetMod("nonexistent"))
+
+	wc.EnableMod("technic")
+	wc.EnableMod("mymod")
+	wc.EnableMod("newmod")
+	wc.DisableMod("mesecons")
+	wc.SetModPath("othermod", "mods/othermod")
+
+	assert.Equal(t, []string{"technic", "mymod", "newmod", "othermod"}, wc.EnabledMods())
+	assert.Equal(t, "mods/mymod", wc.GetMod("mymod").Path)
+	assert.Equal(t, "false", wc.Get("load_mod_mesecons"))
+	assert.Equal(t, "true", wc.Get("load_mod_newmod"))
+}
@@ -1,15 +1,12 @@
 package worldconfig
 
-import (
-	"bufio"
-	"os"
-	"strings"
-)
-
 const (
 	BACKEND_SQLITE3  = "sqlite3"
 	BACKEND_FILES    = "files"
 	BACKEND_POSTGRES = "postgresql"
+	BACKEND_DUMMY    = "dummy"
+	BACKEND_LEVELDB  = "leveldb"
+	BACKEND_REDIS    = "redis"
 )
 
 const (
@@ -22,6 +19,11 @@ const (
 	CONFIG_PSQL_MAP_CONNECTION         = "pgsql_connection"
 	CONFIG_PSQL_AUTH_CONNECTION        = "pgsql_auth_connection"
 	CONFIG_PSQL_MOD_STORAGE_CONNECTION = "pgsql_mod_storage_connection"
+	CONFIG_GAMEID                      = "gameid"
+	CONFIG_WORLD_NAME                  = "world_name"
+	CONFIG_CREATIVE_MODE               = "creative_mode"
+	CONFIG_ENABLE_DAMAGE               = "enable_damage"
+	CONFIG_LOAD_MOD_PREFIX             = "load_mod_"
 )
 
 const DEFAULT_CONFIG = `
@@ -31,28 +33,11 @@ player_backend = sqlite3
 backend = sqlite3
 `
 
+// Parse reads the world.mt file into map form, see Load() for an editable version
 func Parse(filename string) (map[string]string, error) {
-	file, err := os.Open(filename)
+	wc, err := Load(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	cfg := make(map[string]string)
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		sepIndex := strings.Index(line, "=")
-		if sepIndex < 0 {
-			continue
-		}
-
-		valueStr := strings.Trim(line[sepIndex+1:], " ")
-		keyStr := strings.Trim(line[:sepIndex], " ")
-
-		cfg[keyStr] = valueStr
-	}
-
-	return cfg, nil
+	return wc.ToMap(), nil
 }
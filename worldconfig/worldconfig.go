@@ -0,0 +1,221 @@
+package worldconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// a single line of the world.mt file
+type configLine struct {
+	// unmodified line content, without the newline
+	raw string
+	// setting key, empty for comments and blank lines
+	key   string
+	value string
+}
+
+func parseLine(raw string) *configLine {
+	l := &configLine{raw: raw}
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return l
+	}
+	sepIndex := strings.Index(trimmed, "=")
+	if sepIndex < 0 {
+		return l
+	}
+	l.key = strings.TrimSpace(trimmed[:sepIndex])
+	l.value = strings.TrimSpace(trimmed[sepIndex+1:])
+	return l
+}
+
+// WorldConfig is the editable content of a world.mt file,
+// comments, blank lines and the order of the settings are preserved
+type WorldConfig struct {
+	lines []*configLine
+}
+
+// NewWorldConfig creates an empty world config
+func NewWorldConfig() *WorldConfig {
+	return &WorldConfig{lines: []*configLine{{}}}
+}
+
+// Read parses the world config from the given reader
+func Read(r io.Reader) (*WorldConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	wc := &WorldConfig{}
+	for _, raw := range strings.Split(string(data), "\n") {
+		wc.lines = append(wc.lines, parseLine(raw))
+	}
+	return wc, nil
+}
+
+// Load reads the world config from the given file
+func Load(filename string) (*WorldConfig, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Bytes returns the serialized world config
+func (wc *WorldConfig) Bytes() []byte {
+	raws := make([]string, len(wc.lines))
+	for i, l := range wc.lines {
+		raws[i] = l.raw
+	}
+	return []byte(strings.Join(raws, "\n"))
+}
+
+// Save writes the world config atomically to the given file (via a temporary file in the same directory)
+func (wc *WorldConfig) Save(filename string) error {
+	mode := os.FileMode(0644)
+	info, err := os.Stat(filename)
+	if err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmpfile, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile.Name())
+
+	_, err = io.Copy(tmpfile, bytes.NewReader(wc.Bytes()))
+	if err == nil {
+		err = tmpfile.Sync()
+	}
+	if cerr := tmpfile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmpfile.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpfile.Name(), filename)
+}
+
+// returns the last line with the given key or nil
+func (wc *WorldConfig) find(key string) *configLine {
+	for i := len(wc.lines) - 1; i >= 0; i-- {
+		if wc.lines[i].key == key {
+			return wc.lines[i]
+		}
+	}
+	return nil
+}
+
+// Keys returns all setting keys in file order
+func (wc *WorldConfig) Keys() []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, l := range wc.lines {
+		if l.key != "" && !seen[l.key] {
+			keys = append(keys, l.key)
+			seen[l.key] = true
+		}
+	}
+	return keys
+}
+
+// Has returns true if the setting exists
+func (wc *WorldConfig) Has(key string) bool {
+	return wc.find(key) != nil
+}
+
+// Get returns the value of the setting or an empty string if not set
+func (wc *WorldConfig) Get(key string) string {
+	l := wc.find(key)
+	if l == nil {
+		return ""
+	}
+	return l.value
+}
+
+// GetBool returns the boolean value of the setting or the default value if not set or not a boolean
+func (wc *WorldConfig) GetBool(key string, defaultValue bool) bool {
+	v, err := strconv.ParseBool(wc.Get(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// GetInt returns the integer value of the setting or the default value if not set or not an integer
+func (wc *WorldConfig) GetInt(key string, defaultValue int) int {
+	v, err := strconv.Atoi(wc.Get(key))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// Set changes the value of an existing setting in-place or appends a new one
+func (wc *WorldConfig) Set(key, value string) {
+	l := wc.find(key)
+	if l != nil {
+		if l.value != value {
+			crlf := strings.HasSuffix(l.raw, "\r")
+			l.value = value
+			l.raw = fmt.Sprintf("%s = %s", key, value)
+			if crlf {
+				// keep windows line endings
+				l.raw += "\r"
+			}
+		}
+		return
+	}
+
+	l = &configLine{key: key, value: value, raw: fmt.Sprintf("%s = %s", key, value)}
+	last := len(wc.lines) - 1
+	if last >= 0 && wc.lines[last].raw == "" {
+		// insert before the trailing newline
+		wc.lines = append(wc.lines[:last], l, wc.lines[last])
+	} else {
+		wc.lines = append(wc.lines, l)
+	}
+}
+
+// SetBool sets the setting to "true" or "false"
+func (wc *WorldConfig) SetBool(key string, value bool) {
+	wc.Set(key, strconv.FormatBool(value))
+}
+
+// SetInt sets the setting to the given integer
+func (wc *WorldConfig) SetInt(key string, value int) {
+	wc.Set(key, strconv.Itoa(value))
+}
+
+// Remove removes all occurrences of the setting
+func (wc *WorldConfig) Remove(key string) {
+	lines := []*configLine{}
+	for _, l := range wc.lines {
+		if l.key != key {
+			lines = append(lines, l)
+		}
+	}
+	wc.lines = lines
+}
+
+// ToMap returns the settings in map form, as used by mtdb.NewWithConfig
+func (wc *WorldConfig) ToMap() map[string]string {
+	cfg := make(map[string]string)
+	for _, l := range wc.lines {
+		if l.key != "" {
+			cfg[l.key] = l.value
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,96 @@
+package worldconfig_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/minetest-go/mtdb/worldconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorldConfigRoundtrip(t *testing.T) {
+	for _, filename := range []string{"world.mt", "world.mt.sqlite", "world.mt.postgres", "world.mt.comments"} {
+		data, err := os.ReadFile(path.Join("testdata", filename))
+		assert.NoError(t, err)
+
+		wc, err := worldconfig.Load(path.Join("testdata", filename))
+		assert.NoError(t, err)
+		assert.Equal(t, string(data), string(wc.Bytes()))
+	}
+}
+
+func TestWorldConfigAccess(t *testing.T) {
+	wc, err := worldconfig.Load("testdata/world.mt.comments")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "my world", wc.Get("world_name"))
+	assert.Equal(t, worldconfig.BACKEND_SQLITE3, wc.Get(worldconfig.CONFIG_AUTH_BACKEND))
+	assert.True(t, wc.Has("creative_mode"))
+	assert.False(t, wc.Has("# world settings"))
+	assert.False(t, wc.GetBool("creative_mode", true))
+	assert.True(t, wc.GetBool("enable_damage", false))
+	assert.True(t, wc.GetBool("nonexistent", true))
+	assert.Equal(t, 5, wc.GetInt("nonexistent", 5))
+	assert.Equal(t, "", wc.Get("nonexistent"))
+	assert.Equal(t, []string{
+		"gameid", "world_name", "backend", "auth_backend", "player_backend", "mod_storage_backend",
+		"creative_mode", "enable_damage", "load_mod_mesecons", "load_mod_technic", "load_mod_mymod",
+	}, wc.Keys())
+
+	// change in-place
+	wc.Set(worldconfig.CONFIG_AUTH_BACKEND, worldconfig.BACKEND_POSTGRES)
+	wc.SetBool("creative_mode", true)
+	wc.SetInt("max_users", 10)
+	wc.Remove("load_mod_technic")
+
+	lines := strings.Split(string(wc.Bytes()), "\n")
+	assert.Equal(t, "# world settings", lines[0])
+	assert.Equal(t, "auth_backend = postgresql", lines[6])
+	assert.Equal(t, "creative_mode = true", lines[10])
+	assert.Equal(t, "load_mod_mymod = mods/mymod", lines[13])
+	assert.Equal(t, "max_users = 10", lines[14])
+	assert.Equal(t, "", lines[15])
+	assert.Equal(t, 16, len(lines))
+
+	m := wc.ToMap()
+	assert.Equal(t, worldconfig.BACKEND_POSTGRES, m[worldconfig.CONFIG_AUTH_BACKEND])
+	assert.Equal(t, "", m["load_mod_technic"])
+}
+
+func TestWorldConfigSave(t *testing.T) {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "worldconfig")
+	assert.NoError(t, err)
+	filename := path.Join(tmpdir, "world.mt")
+
+	wc := worldconfig.NewWorldConfig()
+	wc.Set(worldconfig.CONFIG_MAP_BACKEND, worldconfig.BACKEND_SQLITE3)
+	assert.NoError(t, wc.Save(filename))
+
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "backend = sqlite3\n", string(data))
+
+	wc, err = worldconfig.Load(filename)
+	assert.NoError(t, err)
+	wc.Set("pgsql_connection", "host=localhost")
+	assert.NoError(t, wc.Save(filename))
+
+	cfg, err := worldconfig.Parse(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "host=localhost", cfg[worldconfig.CONFIG_PSQL_MAP_CONNECTION])
+
+	// no leftover temp files
+	entries, err := os.ReadDir(tmpdir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestWorldConfigCRLF(t *testing.T) {
+	wc, err := worldconfig.Read(strings.NewReader("backend = sqlite3\r\ngameid = x\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite3", wc.Get("backend"))
+	wc.Set("backend", "postgresql")
+	assert.Equal(t, "backend = postgresql\r\ngameid = x\r\n", string(wc.Bytes()))
+}